@@ -51,5 +51,4 @@ done
 
 echo ">> 4: Attempt creating manifest list on registry ${_REGISTRY}"
 
-sed s,__REGISTRY__,${_REGISTRY}, test-registry-tag.yml >test-registry.yaml
-manifest-tool --debug push from-spec test-registry.yaml
+manifest-tool --debug push from-spec --set REGISTRY=${_REGISTRY} test-registry-tag.yml
@@ -43,6 +43,5 @@ done
 
 echo ">> 3: Creating manifest list on registry ${_REGISTRY}"
 
-sed s,__REGISTRY__,${_REGISTRY}, test-registry.yml >test-registry.yaml
-manifest-tool --debug push from-spec test-registry.yaml
+manifest-tool --debug push from-spec --set REGISTRY=${_REGISTRY} test-registry.yml
 
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/estesp/manifest-tool/v2/pkg/store"
+	"github.com/estesp/manifest-tool/v2/pkg/types"
+
+	"github.com/fatih/color"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// buildkitMetadata is the key of the BuildKit-specific provenance metadata, which
+// holds the version control details of the build source
+const buildkitMetadata = "https://mobyproject.org/buildkit@v1#metadata"
+
+// attestationSummary contains the details of an in-toto statement displayed in
+// human-readable inspect output
+type attestationSummary struct {
+	PredicateType string
+	BuilderID     string
+	BuildType     string
+	SourceRepo    string
+	SourceCommit  string
+	Materials     []material
+	// Packages is the number of packages (SPDX) or components (CycloneDX) of an SBOM
+	Packages int
+}
+
+type material struct {
+	URI    string            `json:"uri"`
+	Digest map[string]string `json:"digest"`
+}
+
+type vcsMetadata struct {
+	VCS struct {
+		Source   string `json:"source"`
+		Revision string `json:"revision"`
+	} `json:"vcs"`
+}
+
+// slsaProvenanceV02 contains the fields of a SLSA v0.2 provenance predicate used in the summary
+type slsaProvenanceV02 struct {
+	Builder struct {
+		ID string `json:"id"`
+	} `json:"builder"`
+	BuildType  string `json:"buildType"`
+	Invocation struct {
+		ConfigSource struct {
+			URI    string            `json:"uri"`
+			Digest map[string]string `json:"digest"`
+		} `json:"configSource"`
+	} `json:"invocation"`
+	Materials []material                 `json:"materials"`
+	Metadata  map[string]json.RawMessage `json:"metadata"`
+}
+
+// slsaProvenanceV1 contains the fields of a SLSA v1 provenance predicate used in the summary
+type slsaProvenanceV1 struct {
+	BuildDefinition struct {
+		BuildType          string `json:"buildType"`
+		ExternalParameters struct {
+			ConfigSource struct {
+				URI    string            `json:"uri"`
+				Digest map[string]string `json:"digest"`
+			} `json:"configSource"`
+			Workflow struct {
+				Repository string `json:"repository"`
+				Ref        string `json:"ref"`
+			} `json:"workflow"`
+		} `json:"externalParameters"`
+		ResolvedDependencies []material `json:"resolvedDependencies"`
+	} `json:"buildDefinition"`
+	RunDetails struct {
+		Builder struct {
+			ID string `json:"id"`
+		} `json:"builder"`
+		Metadata map[string]json.RawMessage `json:"metadata"`
+	} `json:"runDetails"`
+}
+
+// attestationStatements decodes the in-toto statements stored in the layers of an
+// attestation manifest; layers which have not been fetched into the store are skipped.
+// A statement without a predicate type takes the one from its layer annotation.
+func attestationStatements(cs store.ContentStore, man ocispec.Manifest) ([]types.InTotoStatement, []json.RawMessage, error) {
+	var (
+		statements []types.InTotoStatement
+		raw        []json.RawMessage
+	)
+	for _, layer := range man.Layers {
+		if layer.MediaType != types.MediaTypeInToto {
+			continue
+		}
+		_, lb, found := cs.Get(layer)
+		if !found || len(lb) == 0 {
+			continue
+		}
+		var statement types.InTotoStatement
+		if err := json.Unmarshal(lb, &statement); err != nil {
+			return nil, nil, fmt.Errorf("error decoding in-toto statement %s: %w", layer.Digest, err)
+		}
+		if statement.PredicateType == "" {
+			statement.PredicateType = layer.Annotations[types.AnnotationInTotoPredicateType]
+		}
+		statements = append(statements, statement)
+		raw = append(raw, json.RawMessage(lb))
+	}
+	return statements, raw, nil
+}
+
+// summarizeStatement extracts the provenance or SBOM details of a statement based on its predicate type
+func summarizeStatement(statement types.InTotoStatement) attestationSummary {
+	summary := attestationSummary{
+		PredicateType: statement.PredicateType,
+	}
+	switch statement.PredicateType {
+	case types.PredicateSLSAProvenanceV02:
+		var p slsaProvenanceV02
+		if err := json.Unmarshal(statement.Predicate, &p); err != nil {
+			return summary
+		}
+		summary.BuilderID = p.Builder.ID
+		summary.BuildType = p.BuildType
+		summary.Materials = p.Materials
+		summary.SourceRepo = p.Invocation.ConfigSource.URI
+		summary.SourceCommit = p.Invocation.ConfigSource.Digest["sha1"]
+		setVCSSource(&summary, p.Metadata)
+	case types.PredicateSLSAProvenanceV1:
+		var p slsaProvenanceV1
+		if err := json.Unmarshal(statement.Predicate, &p); err != nil {
+			return summary
+		}
+		def := p.BuildDefinition
+		summary.BuilderID = p.RunDetails.Builder.ID
+		summary.BuildType = def.BuildType
+		summary.Materials = def.ResolvedDependencies
+		summary.SourceRepo = def.ExternalParameters.ConfigSource.URI
+		summary.SourceCommit = def.ExternalParameters.ConfigSource.Digest["sha1"]
+		if summary.SourceRepo == "" {
+			summary.SourceRepo = def.ExternalParameters.Workflow.Repository
+		}
+		setVCSSource(&summary, p.RunDetails.Metadata)
+	case types.PredicateSPDX:
+		var p struct {
+			Packages []json.RawMessage `json:"packages"`
+		}
+		if err := json.Unmarshal(statement.Predicate, &p); err == nil {
+			summary.Packages = len(p.Packages)
+		}
+	case types.PredicateCycloneDX:
+		var p struct {
+			Components []json.RawMessage `json:"components"`
+		}
+		if err := json.Unmarshal(statement.Predicate, &p); err == nil {
+			summary.Packages = len(p.Components)
+		}
+	}
+	return summary
+}
+
+// setVCSSource prefers the version control details recorded by BuildKit, if available
+func setVCSSource(summary *attestationSummary, metadata map[string]json.RawMessage) {
+	bk, ok := metadata[buildkitMetadata]
+	if !ok {
+		return
+	}
+	var vcs vcsMetadata
+	if err := json.Unmarshal(bk, &vcs); err != nil {
+		return
+	}
+	if vcs.VCS.Source != "" {
+		summary.SourceRepo = vcs.VCS.Source
+	}
+	if vcs.VCS.Revision != "" {
+		summary.SourceCommit = vcs.VCS.Revision
+	}
+}
+
+// outputAttestation writes the summary of each decoded statement of an attestation manifest
+func outputAttestation(outputStr *strings.Builder, label, indent string, statements []types.InTotoStatement) {
+	var (
+		yellow = color.New(color.Bold, color.FgYellow).SprintFunc()
+		red    = color.New(color.Bold, color.FgRed).SprintFunc()
+		green  = color.New(color.Bold, color.FgGreen).SprintFunc()
+	)
+	if len(statements) == 0 {
+		outputStr.WriteString(fmt.Sprintf("%s       >>> No in-toto statements found\n", label))
+		return
+	}
+	for _, statement := range statements {
+		summary := summarizeStatement(statement)
+		outputStr.WriteString(fmt.Sprintf("%s Statement: %s\n", label, green(summary.PredicateType)))
+		if summary.BuilderID != "" {
+			outputStr.WriteString(fmt.Sprintf("%s    -   Builder: %s\n", label, green(summary.BuilderID)))
+		}
+		if summary.BuildType != "" {
+			outputStr.WriteString(fmt.Sprintf("%s    -     Build: %s\n", label, green(summary.BuildType)))
+		}
+		if summary.SourceRepo != "" {
+			outputStr.WriteString(fmt.Sprintf("%s    -    Source: %s\n", label, green(summary.SourceRepo)))
+		}
+		if summary.SourceCommit != "" {
+			outputStr.WriteString(fmt.Sprintf("%s    -    Commit: %s\n", label, yellow(summary.SourceCommit)))
+		}
+		switch summary.PredicateType {
+		case types.PredicateSPDX, types.PredicateCycloneDX:
+			outputStr.WriteString(fmt.Sprintf("%s    -  Packages: %s\n", label, red(summary.Packages)))
+		case types.PredicateSLSAProvenanceV02, types.PredicateSLSAProvenanceV1:
+			outputStr.WriteString(fmt.Sprintf("%s    - Materials: %s\n", label, red(len(summary.Materials))))
+			for j, m := range summary.Materials {
+				outputStr.WriteString(fmt.Sprintf("%s     material %s: uri = %s\n", indent, red(fmt.Sprintf("%02d", j+1)), green(m.URI)))
+				if d := materialDigest(m.Digest); d != "" {
+					outputStr.WriteString(fmt.Sprintf("%s               digest = %s\n", indent, yellow(d)))
+				}
+			}
+		}
+	}
+}
+
+// materialDigest formats the digest set of a material as algorithm:value pairs
+func materialDigest(digests map[string]string) string {
+	var parts []string
+	for alg, value := range digests {
+		parts = append(parts, alg+":"+value)
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ", ")
+}
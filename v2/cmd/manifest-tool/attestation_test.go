@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/estesp/manifest-tool/v2/pkg/store"
+	"github.com/estesp/manifest-tool/v2/pkg/types"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+func TestSummarizeStatement(t *testing.T) {
+	var tests = []struct {
+		name      string
+		statement string
+		want      attestationSummary
+	}{
+		{
+			name: "slsa v0.2",
+			statement: `{"_type": "https://in-toto.io/Statement/v0.1", "predicateType": "https://slsa.dev/provenance/v0.2", "predicate": {
+				"builder": {"id": "https://github.com/docker/buildx"}, "buildType": "https://mobyproject.org/buildkit@v1",
+				"invocation": {"configSource": {"uri": "https://github.com/org/app.git", "digest": {"sha1": "abc123"}}},
+				"materials": [{"uri": "pkg:docker/alpine@3.19", "digest": {"sha256": "aa"}}]}}`,
+			want: attestationSummary{
+				PredicateType: types.PredicateSLSAProvenanceV02,
+				BuilderID:     "https://github.com/docker/buildx",
+				BuildType:     "https://mobyproject.org/buildkit@v1",
+				SourceRepo:    "https://github.com/org/app.git",
+				SourceCommit:  "abc123",
+				Materials:     []material{{URI: "pkg:docker/alpine@3.19", Digest: map[string]string{"sha256": "aa"}}},
+			},
+		},
+		{
+			name: "slsa v0.2 with buildkit vcs metadata",
+			statement: `{"predicateType": "https://slsa.dev/provenance/v0.2", "predicate": {
+				"invocation": {"configSource": {"uri": "context"}},
+				"metadata": {"https://mobyproject.org/buildkit@v1#metadata": {"vcs": {"source": "https://github.com/org/app", "revision": "def456"}}}}}`,
+			want: attestationSummary{
+				PredicateType: types.PredicateSLSAProvenanceV02,
+				SourceRepo:    "https://github.com/org/app",
+				SourceCommit:  "def456",
+			},
+		},
+		{
+			name: "slsa v1",
+			statement: `{"predicateType": "https://slsa.dev/provenance/v1", "predicate": {
+				"buildDefinition": {"buildType": "https://actions.github.io/buildtypes/workflow/v1",
+					"externalParameters": {"workflow": {"repository": "https://github.com/org/app", "ref": "refs/heads/main"}},
+					"resolvedDependencies": [{"uri": "git+https://github.com/org/app", "digest": {"gitCommit": "0123"}}]},
+				"runDetails": {"builder": {"id": "https://github.com/actions/runner"}}}}`,
+			want: attestationSummary{
+				PredicateType: types.PredicateSLSAProvenanceV1,
+				BuilderID:     "https://github.com/actions/runner",
+				BuildType:     "https://actions.github.io/buildtypes/workflow/v1",
+				SourceRepo:    "https://github.com/org/app",
+				Materials:     []material{{URI: "git+https://github.com/org/app", Digest: map[string]string{"gitCommit": "0123"}}},
+			},
+		},
+		{
+			name:      "spdx",
+			statement: `{"predicateType": "https://spdx.dev/Document", "predicate": {"spdxVersion": "SPDX-2.3", "packages": [{"name": "a"}, {"name": "b"}, {"name": "c"}]}}`,
+			want:      attestationSummary{PredicateType: types.PredicateSPDX, Packages: 3},
+		},
+		{
+			name:      "unknown predicate type",
+			statement: `{"predicateType": "https://example.com/custom/v1", "predicate": {"builder": {"id": "x"}, "packages": [{}]}}`,
+			want:      attestationSummary{PredicateType: "https://example.com/custom/v1"},
+		},
+		{
+			name:      "provenance predicate of another shape",
+			statement: `{"predicateType": "https://slsa.dev/provenance/v0.2", "predicate": ["not", "an", "object"]}`,
+			want:      attestationSummary{PredicateType: types.PredicateSLSAProvenanceV02},
+		},
+	}
+	for _, tt := range tests {
+		var statement types.InTotoStatement
+		if err := json.Unmarshal([]byte(tt.statement), &statement); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got := summarizeStatement(statement); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: expected %+v, got %+v", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestAttestationStatements(t *testing.T) {
+	cs := store.NewMemoryStore()
+	layer := func(content string, annotations map[string]string) ocispec.Descriptor {
+		desc := ocispec.Descriptor{MediaType: types.MediaTypeInToto, Digest: digest.FromString(content), Size: int64(len(content)), Annotations: annotations}
+		cs.Set(desc, []byte(content))
+		return desc
+	}
+	provenance := layer(`{"_type": "https://in-toto.io/Statement/v0.1", "predicateType": "https://slsa.dev/provenance/v0.2", "predicate": {}}`, nil)
+	// the predicate type is taken from the layer annotation when the statement lacks it
+	sbom := layer(`{"_type": "https://in-toto.io/Statement/v0.1", "predicate": {"packages": []}}`, map[string]string{types.AnnotationInTotoPredicateType: types.PredicateSPDX})
+	notFetched := ocispec.Descriptor{MediaType: types.MediaTypeInToto, Digest: digest.FromString("not fetched"), Size: 11}
+	other := ocispec.Descriptor{MediaType: "application/vnd.example+json", Digest: digest.FromString("other")}
+
+	statements, raw, err := attestationStatements(cs, ocispec.Manifest{Layers: []ocispec.Descriptor{provenance, other, notFetched, sbom}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(statements) != 2 || len(raw) != 2 {
+		t.Fatalf("expected 2 statements, got %d", len(statements))
+	}
+	if statements[0].PredicateType != types.PredicateSLSAProvenanceV02 || statements[1].PredicateType != types.PredicateSPDX {
+		t.Errorf("unexpected predicate types %q and %q", statements[0].PredicateType, statements[1].PredicateType)
+	}
+
+	malformed := layer(`{"_type": "https://in-toto.io/Statement/v0.1", "predicateType": `, nil)
+	_, _, err = attestationStatements(cs, ocispec.Manifest{Layers: []ocispec.Descriptor{provenance, malformed}})
+	if err == nil || !strings.Contains(err.Error(), "error decoding in-toto statement "+malformed.Digest.String()) {
+		t.Errorf("expected an error for the malformed statement, got %v", err)
+	}
+}
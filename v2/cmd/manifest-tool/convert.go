@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/estesp/manifest-tool/v2/pkg/registry"
+	"github.com/estesp/manifest-tool/v2/pkg/store"
+	"github.com/estesp/manifest-tool/v2/pkg/types"
+
+	"github.com/urfave/cli/v2"
+)
+
+var convertCmd = &cli.Command{
+	Name:      "convert",
+	Usage:     "convert a manifest list to an OCI index or an OCI index to a manifest list",
+	ArgsUsage: "<image reference>",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:     "to",
+			Usage:    "manifest type to convert to: docker (v2.2 manifest list) or oci (v1 index)",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:  "target",
+			Usage: "image reference to push the converted manifest list/index to instead of the image reference itself",
+		},
+	},
+	Action: func(c *cli.Context) error {
+		image := c.Args().First()
+		if image == "" {
+			return fmt.Errorf("an image reference must be provided")
+		}
+		manifestType, err := parseManifestType(c.String("to"))
+		if err != nil || manifestType == types.Auto {
+			return fmt.Errorf("invalid --to value %q: expected docker or oci", c.String("to"))
+		}
+		memoryStore, err := store.NewCachedMemoryStore(c.String("cache-dir"))
+		if err != nil {
+			return err
+		}
+		digest, length, err := registry.ConvertManifestList(c.String("username"), c.String("password"), image, c.String("target"), manifestType, c.Bool("insecure"), c.Bool("plain-http"), c.String("docker-cfg"), memoryStore)
+		if err != nil {
+			return fmt.Errorf("failed to convert image: %w", err)
+		}
+		fmt.Printf("Digest: %s %d\n", digest, length)
+		return nil
+	},
+}
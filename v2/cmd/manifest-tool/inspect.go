@@ -1,9 +1,15 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
+	"os"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/docker/distribution/reference"
 	"github.com/estesp/manifest-tool/v2/pkg/registry"
@@ -12,9 +18,11 @@ import (
 	"github.com/estesp/manifest-tool/v2/pkg/util"
 
 	"github.com/fatih/color"
+	"github.com/opencontainers/go-digest"
 	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/sirupsen/logrus"
 	"github.com/urfave/cli/v2"
+	"golang.org/x/sync/errgroup"
 )
 
 var inspectCmd = &cli.Command{
@@ -29,172 +37,654 @@ var inspectCmd = &cli.Command{
 			Name:  "expand-config",
 			Usage: "expand image config content in raw JSON output",
 		},
+		&cli.BoolFlag{
+			Name:  "verbose",
+			Usage: "include image config details (entrypoint, env, labels, history, ...) for each platform in human-readable output",
+		},
+		&cli.BoolFlag{
+			Name:  "attestations",
+			Usage: "fetch and decode the in-toto statements (provenance, SBOM) of attestation manifests",
+		},
+		&cli.StringFlag{
+			Name:  "file",
+			Usage: "file containing a list of image references to inspect, one per line (use - for stdin)",
+		},
+		&cli.IntFlag{
+			Name:  "parallel",
+			Value: 8,
+			Usage: "maximum number of image references inspected concurrently",
+		},
+		&cli.BoolFlag{
+			Name:  "json-lines",
+			Usage: "emit one compact JSON document per line for raw output instead of a JSON array when inspecting multiple references",
+		},
 	},
 	Action: func(c *cli.Context) error {
 
-		name := c.Args().First()
-		imageRef, err := util.ParseName(name)
+		if c.Bool("expand-config") && !c.Bool("raw") {
+			return fmt.Errorf("the --expand-config flag is only valid when used with --raw")
+		}
+		if c.Bool("verbose") && c.Bool("raw") {
+			return fmt.Errorf("the --verbose flag is not valid with --raw; use --expand-config to include image config content")
+		}
+		if c.Bool("json-lines") && !c.Bool("raw") {
+			return fmt.Errorf("the --json-lines flag is only valid when used with --raw")
+		}
+		names, err := inspectNames(c.Args().Slice(), c.String("file"), os.Stdin)
 		if err != nil {
-			return fmt.Errorf("error parsing image reference: %w", err)
+			return err
+		}
+		if len(names) == 0 {
+			return fmt.Errorf("at least one image reference must be provided as an argument or via --file")
 		}
-		if _, ok := imageRef.(reference.NamedTagged); !ok {
-			if _, ok := imageRef.(reference.Digested); !ok {
-				return fmt.Errorf("image reference must include a tag or a digest; manifest-tool does not default to 'latest'")
+		if len(names) == 1 && !c.Bool("json-lines") {
+			out, err := inspectImage(c, names[0])
+			if err != nil {
+				return err
 			}
+			fmt.Print(out)
+			return nil
 		}
-
-		if c.Bool("expand-config") && !c.Bool("raw") {
-			return fmt.Errorf("the --expand-config flag is only valid when used with --raw")
+		inspect := func(name string) (string, error) {
+			return inspectImage(c, name)
 		}
-		memoryStore := store.NewMemoryStore()
-		err = util.CreateRegistryHost(imageRef, c.String("username"), c.String("password"), c.Bool("insecure"),
-			c.Bool("plain-http"), c.String("docker-cfg"), false)
+		return inspectImages(os.Stdout, names, c.Int("parallel"), c.Bool("raw"), c.Bool("json-lines"), inspect)
+	},
+}
+
+// inspectNames collects the image references to inspect from the command
+// arguments followed by those listed in the --file input (one per line,
+// ignoring blank lines and '#' comments; "-" reads the list from stdin)
+func inspectNames(args []string, listFile string, stdin io.Reader) ([]string, error) {
+	names := args
+	if listFile == "" {
+		return names, nil
+	}
+	r := stdin
+	if listFile != "-" {
+		f, err := os.Open(listFile)
 		if err != nil {
-			return fmt.Errorf("error creating registry host configuration: %w", err)
+			return nil, fmt.Errorf("cannot open image reference list %q: %w", listFile, err)
+		}
+		defer f.Close() //nolint:errcheck
+		r = f
+	}
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
 		}
+		names = append(names, line)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("cannot read image reference list %q: %w", listFile, err)
+	}
+	return names, nil
+}
 
-		descriptor, err := registry.FetchDescriptor(util.GetResolver(), memoryStore, imageRef)
-		if err != nil {
-			return fmt.Errorf("error fetching image descriptor: %w", err)
+// inspectResult holds the output of inspecting one image reference of a
+// multi-reference inspect invocation
+type inspectResult struct {
+	name string
+	out  string
+	err  error
+	done chan struct{}
+}
+
+// inspectImages inspects many image references concurrently with the inspect function,
+// limited to parallel operations at a time, and writes the results to w in the order the
+// references were given. Raw output is written as a JSON array, or as one JSON document
+// per line for jsonLines. A failed reference is reported without stopping the remaining
+// inspections.
+func inspectImages(w io.Writer, names []string, parallel int, raw, jsonLines bool, inspect func(name string) (string, error)) error {
+	results := make([]*inspectResult, len(names))
+	for i, name := range names {
+		results[i] = &inspectResult{name: name, done: make(chan struct{})}
+	}
+	var g errgroup.Group
+	g.SetLimit(max(parallel, 1))
+	fed := make(chan struct{})
+	go func() {
+		defer close(fed)
+		for _, res := range results {
+			g.Go(func() error {
+				res.out, res.err = inspect(res.name)
+				close(res.done)
+				return nil
+			})
 		}
+	}()
+	// all inspections are started by the feeder before waiting for them to finish
+	defer func() {
+		<-fed
+		_ = g.Wait()
+	}()
 
-		if c.Bool("raw") {
-			out, err := generateRawJSON(name, descriptor, c.Bool("expand-config"), memoryStore)
+	var failed int
+	if raw && !jsonLines {
+		fmt.Fprintln(w, "[")
+	}
+	for i, res := range results {
+		<-res.done
+		out := res.out
+		if res.err != nil {
+			failed++
+			logrus.Errorf("inspect of image %q failed: %v", res.name, res.err)
+			if !raw {
+				continue
+			}
+			b, err := json.Marshal(inspectErrorJson{Name: res.name, Error: res.err.Error()})
 			if err != nil {
-				return fmt.Errorf("error while generating raw JSON output: %w", err)
+				return err
 			}
-			fmt.Println(string(out))
-			return nil
+			out = string(b)
 		}
-		_, db, _ := memoryStore.Get(descriptor)
-		switch descriptor.MediaType {
-		case ocispec.MediaTypeImageIndex, types.MediaTypeDockerSchema2ManifestList:
-			// this is a multi-platform image descriptor; marshal to Index type
-			var idx ocispec.Index
-			if err := json.Unmarshal(db, &idx); err != nil {
-				return fmt.Errorf("error while unmarshalling the OCI index: %w", err)
-			}
-			outputList(name, memoryStore, descriptor, idx)
-		case ocispec.MediaTypeImageManifest, types.MediaTypeDockerSchema2Manifest:
-			var man ocispec.Manifest
-			if err := json.Unmarshal(db, &man); err != nil {
-				return fmt.Errorf("error while unmarshalling the OCI image manifest: %w", err)
-			}
-			_, cb, _ := memoryStore.Get(man.Config)
-			var conf ocispec.Image
-			if err := json.Unmarshal(cb, &conf); err != nil {
-				return fmt.Errorf("error while unmarshalling the OCI image configuration: %w", err)
+		if !raw {
+			fmt.Fprint(w, out)
+			continue
+		}
+		var buf bytes.Buffer
+		if jsonLines {
+			if err := json.Compact(&buf, []byte(out)); err != nil {
+				return fmt.Errorf("error while generating raw JSON output: %w", err)
 			}
-			outputImage(name, descriptor, man, conf)
-		default:
-			return fmt.Errorf("unknown descriptor type: %s", descriptor.MediaType)
+			fmt.Fprintln(w, buf.String())
+			continue
+		}
+		if err := json.Indent(&buf, []byte(strings.TrimSpace(out)), "    ", "    "); err != nil {
+			return fmt.Errorf("error while generating raw JSON output: %w", err)
 		}
+		sep := ","
+		if i == len(results)-1 {
+			sep = ""
+		}
+		fmt.Fprintf(w, "    %s%s\n", buf.String(), sep)
+	}
+	if raw && !jsonLines {
+		fmt.Fprintln(w, "]")
+	}
+	if failed > 0 {
+		return fmt.Errorf("inspect failed for %d of %d image references", failed, len(names))
+	}
+	return nil
+}
 
-		return nil
-	},
+// inspectImage fetches the manifests and configs of an image reference and returns
+// the human-readable or raw JSON output for it
+func inspectImage(c *cli.Context, name string) (string, error) {
+	imageRef, err := util.ParseName(name)
+	if err != nil {
+		return "", fmt.Errorf("error parsing image reference: %w", err)
+	}
+	if _, ok := imageRef.(reference.NamedTagged); !ok {
+		if _, ok := imageRef.(reference.Digested); !ok {
+			return "", fmt.Errorf("image reference must include a tag or a digest; manifest-tool does not default to 'latest'")
+		}
+	}
+
+	memoryStore, err := store.NewCachedMemoryStore(c.String("cache-dir"))
+	if err != nil {
+		return "", err
+	}
+	err = util.CreateRegistryHost(imageRef, c.String("username"), c.String("password"), c.Bool("insecure"),
+		c.Bool("plain-http"), c.String("docker-cfg"), false)
+	if err != nil {
+		return "", fmt.Errorf("error creating registry host configuration: %w", err)
+	}
+
+	descriptor, err := registry.FetchDescriptor(util.GetResolver(), memoryStore, imageRef)
+	if err != nil {
+		return "", fmt.Errorf("error fetching image descriptor: %w", err)
+	}
+	if c.Bool("attestations") {
+		if err := registry.FetchAttestationContent(util.GetResolver(), memoryStore, imageRef, descriptor); err != nil {
+			return "", fmt.Errorf("error fetching attestation content: %w", err)
+		}
+	}
+
+	if c.Bool("raw") {
+		out, err := generateRawJSON(name, descriptor, c.Bool("expand-config"), c.Bool("attestations"), memoryStore)
+		if err != nil {
+			return "", fmt.Errorf("error while generating raw JSON output: %w", err)
+		}
+		return out + "\n", nil
+	}
+	var out strings.Builder
+	_, db, _ := memoryStore.Get(descriptor)
+	switch descriptor.MediaType {
+	case ocispec.MediaTypeImageIndex, types.MediaTypeDockerSchema2ManifestList:
+		// this is a multi-platform image descriptor; marshal to Index type
+		var idx ocispec.Index
+		if err := json.Unmarshal(db, &idx); err != nil {
+			return "", fmt.Errorf("error while unmarshalling the OCI index: %w", err)
+		}
+		if err := outputList(&out, name, memoryStore, descriptor, idx, c.Bool("verbose"), c.Bool("attestations")); err != nil {
+			return "", err
+		}
+	case ocispec.MediaTypeImageManifest, types.MediaTypeDockerSchema2Manifest:
+		var man ocispec.Manifest
+		if err := json.Unmarshal(db, &man); err != nil {
+			return "", fmt.Errorf("error while unmarshalling the OCI image manifest: %w", err)
+		}
+		if types.IsArtifactManifest(man) {
+			outputArtifact(&out, name, descriptor, man)
+			break
+		}
+		_, cb, _ := memoryStore.Get(man.Config)
+		var conf ocispec.Image
+		if err := json.Unmarshal(cb, &conf); err != nil {
+			return "", fmt.Errorf("error while unmarshalling the OCI image configuration: %w", err)
+		}
+		outputImage(&out, name, descriptor, man, conf, c.Bool("verbose"))
+	case types.MediaTypeDockerSchema1Manifest, types.MediaTypeDockerSchema1UnsignedManifest:
+		man, v1Images, err := registry.ParseSchema1(db)
+		if err != nil {
+			return "", fmt.Errorf("error while unmarshalling the schema 1 image manifest: %w", err)
+		}
+		outputSchema1(&out, name, descriptor, man, v1Images, c.Bool("verbose"))
+	default:
+		// display what we can of other manifest-like content (e.g. non-standard artifact manifests)
+		var man ocispec.Manifest
+		if err := json.Unmarshal(db, &man); err != nil || (man.Config.Digest == "" && len(man.Layers) == 0) {
+			man = ocispec.Manifest{}
+		}
+		outputArtifact(&out, name, descriptor, man)
+	}
+	return out.String(), nil
 }
 
-func outputList(name string, cs *store.MemoryStore, descriptor ocispec.Descriptor, index ocispec.Index) {
+func outputList(w io.Writer, name string, cs store.ContentStore, descriptor ocispec.Descriptor, index ocispec.Index, verbose, attestationDetails bool) error {
 	var (
 		yellow = color.New(color.Bold, color.FgYellow).SprintFunc()
 		red    = color.New(color.Bold, color.FgRed).SprintFunc()
-		blue   = color.New(color.Bold, color.FgBlue).SprintFunc()
 		green  = color.New(color.Bold, color.FgGreen).SprintFunc()
 	)
-	fmt.Printf("Name:   %s (Type: %s)\n", green(name), green(descriptor.MediaType))
-	fmt.Printf("Digest: %s\n", yellow(descriptor.Digest))
+	fmt.Fprintf(w, "Name:   %s (Type: %s)\n", green(name), green(descriptor.MediaType))
+	fmt.Fprintf(w, "Digest: %s\n", yellow(descriptor.Digest))
 
 	outputStr := strings.Builder{}
-	var attestations int
-	for i, img := range index.Manifests {
+	sizes := newSizeSummary()
+	attestations, indexes, err := outputManifests(&outputStr, cs, index.Manifests, "", 0, verbose, attestationDetails, sizes)
+	if err != nil {
+		return err
+	}
+
+	imageCount := len(index.Manifests) - attestations - indexes
+	imageStr := "image"
+	attestStr := "attestation"
+	if imageCount > 1 {
+		imageStr = "images"
+	}
+	if attestations > 1 {
+		attestStr = "attestations"
+	}
+	var indexDetail string
+	if indexes > 0 {
+		indexStr := "nested index"
+		if indexes > 1 {
+			indexStr = "nested indexes"
+		}
+		indexDetail = fmt.Sprintf(", %s %s", red(indexes), indexStr)
+	}
+	fmt.Fprintf(w, " * Contains %s manifest references (%s %s, %s %s%s):\n", red(len(index.Manifests)),
+		red(imageCount), imageStr, red(attestations), attestStr, indexDetail)
+	sizes.write(&outputStr)
+	fmt.Fprintf(w, "%s", outputStr.String())
+	return nil
+}
+
+// outputManifests writes the human-readable details of each index entry to outputStr,
+// descending into nested indexes. Entries of a nested index are labeled with their
+// parent's label as a prefix (e.g. "[2.1]") and indented one level per depth. The
+// blobs of each platform's image are recorded in sizes, and the number of attestation
+// and nested index entries found at this level are returned. The in-toto statements of
+// attestations are summarized when attestationDetails is set.
+func outputManifests(outputStr *strings.Builder, cs store.ContentStore, manifests []ocispec.Descriptor, parent string, depth int, verbose, attestationDetails bool, sizes *sizeSummary) (int, int, error) {
+	var (
+		yellow = color.New(color.Bold, color.FgYellow).SprintFunc()
+		red    = color.New(color.Bold, color.FgRed).SprintFunc()
+		blue   = color.New(color.Bold, color.FgBlue).SprintFunc()
+		green  = color.New(color.Bold, color.FgGreen).SprintFunc()
+	)
+	var attestations, indexes int
+	indent := strings.Repeat("    ", depth)
+	for i, img := range manifests {
 		var attestationDetail string
 
+		label := fmt.Sprintf("%s[%s%d]", indent, parent, i+1)
 		if aRefType, ok := img.Annotations["vnd.docker.reference.type"]; ok {
 			if aRefType == "attestation-manifest" {
 				attestations++
 				attestationDetail = " (vnd.docker.reference.type=attestation-manifest)"
 			}
 		}
-		outputStr.WriteString(fmt.Sprintf("[%d]     Type: %s%s\n", i+1, green(img.MediaType), green(attestationDetail)))
-		outputStr.WriteString(fmt.Sprintf("[%d]   Digest: %s\n", i+1, yellow(img.Digest)))
-		outputStr.WriteString(fmt.Sprintf("[%d]   Length: %s\n", i+1, blue(img.Size)))
+		outputStr.WriteString(fmt.Sprintf("%s     Type: %s%s\n", label, green(img.MediaType), green(attestationDetail)))
+		outputStr.WriteString(fmt.Sprintf("%s   Digest: %s\n", label, yellow(img.Digest)))
+		outputStr.WriteString(fmt.Sprintf("%s   Length: %s\n", label, blue(img.Size)))
 
 		_, db, _ := cs.Get(img)
 		switch img.MediaType {
 		case ocispec.MediaTypeImageManifest, types.MediaTypeDockerSchema2Manifest:
 			var man ocispec.Manifest
 			if err := json.Unmarshal(db, &man); err != nil {
-				logrus.Fatal(err)
+				return 0, 0, fmt.Errorf("error while unmarshalling the image manifest %s: %w", img.Digest, err)
 			}
 			if len(attestationDetail) > 0 {
 				// only output info about the attestation info
 				attestRef := img.Annotations["vnd.docker.reference.digest"]
-				outputStr.WriteString(fmt.Sprintf("[%d]       >>> Attestation for digest: %s\n\n", i+1, yellow(attestRef)))
+				outputStr.WriteString(fmt.Sprintf("%s       >>> Attestation for digest: %s\n", label, yellow(attestRef)))
+				if attestationDetails {
+					statements, _, err := attestationStatements(cs, man)
+					if err != nil {
+						return 0, 0, err
+					}
+					outputAttestation(outputStr, label, indent, statements)
+				}
+				outputStr.WriteString("\n")
 				continue
 			}
-			outputStr.WriteString(fmt.Sprintf("[%d] Platform:\n", i+1))
-			outputStr.WriteString(fmt.Sprintf("[%d]    -      OS: %s\n", i+1, green(img.Platform.OS)))
-			if img.Platform.OSVersion != "" {
-				outputStr.WriteString(fmt.Sprintf("[%d]    - OS Vers: %s\n", i+1, green(img.Platform.OSVersion)))
-			}
-			if len(img.Platform.OSFeatures) > 0 {
-				outputStr.WriteString(fmt.Sprintf("[%d]    - OS Feat: %s\n", i+1, green(img.Platform.OSFeatures)))
-			}
-			outputStr.WriteString(fmt.Sprintf("[%d]    -    Arch: %s\n", i+1, green(img.Platform.Architecture)))
-			if img.Platform.Variant != "" {
-				outputStr.WriteString(fmt.Sprintf("[%d]    - Variant: %s\n", i+1, green(img.Platform.Variant)))
+			if types.IsArtifactManifest(man) {
+				outputStr.WriteString(fmt.Sprintf("%s     Size: %s\n", label, blue(formatSize(imageSize(man)))))
+				if img.Platform != nil {
+					outputPlatform(outputStr, label, img.Platform)
+				}
+				outputArtifactDetails(outputStr, label, indent, man)
+				outputStr.WriteString("\n")
+				continue
 			}
-			outputStr.WriteString(fmt.Sprintf("[%d] # Layers: %s\n", i+1, red(len(man.Layers))))
+			sizes.add(platformString(img.Platform), man)
+			outputStr.WriteString(fmt.Sprintf("%s     Size: %s\n", label, blue(formatSize(imageSize(man)))))
+			outputPlatform(outputStr, label, img.Platform)
+			outputStr.WriteString(fmt.Sprintf("%s # Layers: %s\n", label, red(len(man.Layers))))
 			for j, layer := range man.Layers {
-				outputStr.WriteString(fmt.Sprintf("     layer %s: digest = %s\n", red(fmt.Sprintf("%02d", j+1)), yellow(layer.Digest)))
-				outputStr.WriteString(fmt.Sprintf("                 type = %s\n", green(layer.MediaType)))
+				outputStr.WriteString(fmt.Sprintf("%s     layer %s: digest = %s\n", indent, red(fmt.Sprintf("%02d", j+1)), yellow(layer.Digest)))
+				outputStr.WriteString(fmt.Sprintf("%s                 type = %s\n", indent, green(layer.MediaType)))
+			}
+			if verbose {
+				_, cb, found := cs.Get(man.Config)
+				if !found {
+					return 0, 0, fmt.Errorf("image configuration %s of manifest %s not found", man.Config.Digest, img.Digest)
+				}
+				var conf ocispec.Image
+				if err := json.Unmarshal(cb, &conf); err != nil {
+					return 0, 0, fmt.Errorf("error while unmarshalling the image configuration %s: %w", man.Config.Digest, err)
+				}
+				outputConfig(outputStr, label, indent, conf)
 			}
 			outputStr.WriteString("\n")
+		case ocispec.MediaTypeImageIndex, types.MediaTypeDockerSchema2ManifestList:
+			indexes++
+			var nested ocispec.Index
+			if err := json.Unmarshal(db, &nested); err != nil {
+				return 0, 0, fmt.Errorf("error while unmarshalling the nested index %s: %w", img.Digest, err)
+			}
+			if img.Platform != nil {
+				outputPlatform(outputStr, label, img.Platform)
+			}
+			outputStr.WriteString(fmt.Sprintf("%s  Entries: %s (nested index)\n\n", label, red(len(nested.Manifests))))
+			if _, _, err := outputManifests(outputStr, cs, nested.Manifests, fmt.Sprintf("%s%d.", parent, i+1), depth+1, verbose, attestationDetails, sizes); err != nil {
+				return 0, 0, err
+			}
 		default:
-			outputStr.WriteString(fmt.Sprintf("Unknown media type for further display: %s\n", img.MediaType))
+			outputStr.WriteString(fmt.Sprintf("%sUnknown media type for further display: %s\n\n", indent, img.MediaType))
 		}
 	}
-	imageCount := len(index.Manifests) - attestations
-	imageStr := "image"
-	attestStr := "attestation"
-	if imageCount > 1 {
-		imageStr = "images"
+	return attestations, indexes, nil
+}
+
+func outputPlatform(outputStr *strings.Builder, label string, platform *ocispec.Platform) {
+	green := color.New(color.Bold, color.FgGreen).SprintFunc()
+	if platform == nil {
+		platform = &ocispec.Platform{}
 	}
-	if attestations > 1 {
-		attestStr = "attestations"
+	outputStr.WriteString(fmt.Sprintf("%s Platform:\n", label))
+	outputStr.WriteString(fmt.Sprintf("%s    -      OS: %s\n", label, green(platform.OS)))
+	if platform.OSVersion != "" {
+		outputStr.WriteString(fmt.Sprintf("%s    - OS Vers: %s\n", label, green(platform.OSVersion)))
+	}
+	if len(platform.OSFeatures) > 0 {
+		outputStr.WriteString(fmt.Sprintf("%s    - OS Feat: %s\n", label, green(platform.OSFeatures)))
+	}
+	outputStr.WriteString(fmt.Sprintf("%s    -    Arch: %s\n", label, green(platform.Architecture)))
+	if platform.Variant != "" {
+		outputStr.WriteString(fmt.Sprintf("%s    - Variant: %s\n", label, green(platform.Variant)))
 	}
-	fmt.Printf(" * Contains %s manifest references (%s %s, %s %s):\n", red(len(index.Manifests)),
-		red(imageCount), imageStr, red(attestations), attestStr)
-	fmt.Printf("%s", outputStr.String())
 }
 
-func outputImage(name string, descriptor ocispec.Descriptor, manifest ocispec.Manifest, config ocispec.Image) {
+func outputImage(w io.Writer, name string, descriptor ocispec.Descriptor, manifest ocispec.Manifest, config ocispec.Image, verbose bool) {
 	var (
 		yellow = color.New(color.Bold, color.FgYellow).SprintFunc()
 		red    = color.New(color.Bold, color.FgRed).SprintFunc()
 		blue   = color.New(color.Bold, color.FgBlue).SprintFunc()
 		green  = color.New(color.Bold, color.FgGreen).SprintFunc()
 	)
-	fmt.Printf("Name: %s (Type: %s)\n", green(name), green(descriptor.MediaType))
-	fmt.Printf("      Digest: %s\n", yellow(descriptor.Digest))
-	fmt.Printf("        Size: %s\n", blue(descriptor.Size))
-	fmt.Printf("          OS: %s\n", green(config.OS))
-	fmt.Printf("        Arch: %s\n", green(config.Architecture))
-	fmt.Printf("    # Layers: %s\n", red(len(manifest.Layers)))
+	fmt.Fprintf(w, "Name: %s (Type: %s)\n", green(name), green(descriptor.MediaType))
+	fmt.Fprintf(w, "      Digest: %s\n", yellow(descriptor.Digest))
+	fmt.Fprintf(w, "        Size: %s\n", blue(descriptor.Size))
+	fmt.Fprintf(w, "  Image Size: %s\n", blue(formatSize(imageSize(manifest))))
+	fmt.Fprintf(w, "          OS: %s\n", green(config.OS))
+	fmt.Fprintf(w, "        Arch: %s\n", green(config.Architecture))
+	fmt.Fprintf(w, "    # Layers: %s\n", red(len(manifest.Layers)))
 	for i, layer := range manifest.Layers {
-		fmt.Printf("      layer %s: digest = %s\n", red(fmt.Sprintf("%02d", i+1)), yellow(layer.Digest))
+		fmt.Fprintf(w, "      layer %s: digest = %s\n", red(fmt.Sprintf("%02d", i+1)), yellow(layer.Digest))
+	}
+	if verbose {
+		outputStr := strings.Builder{}
+		outputConfig(&outputStr, "", " ", config)
+		fmt.Fprintf(w, "%s", outputStr.String())
+	}
+}
+
+// outputSchema1 displays a legacy Docker schema 1 image; its platform and (verbose) config
+// details are taken from the v1 image JSON of its history
+func outputSchema1(w io.Writer, name string, descriptor ocispec.Descriptor, manifest types.Schema1Manifest, v1Images []types.Schema1Image, verbose bool) {
+	var (
+		yellow = color.New(color.Bold, color.FgYellow).SprintFunc()
+		red    = color.New(color.Bold, color.FgRed).SprintFunc()
+		blue   = color.New(color.Bold, color.FgBlue).SprintFunc()
+		green  = color.New(color.Bold, color.FgGreen).SprintFunc()
+	)
+	config := registry.Schema1Config(manifest, v1Images)
+	layers := registry.Schema1Layers(manifest, v1Images)
+	fmt.Fprintf(w, "Name: %s (Type: %s)\n", green(name), green(descriptor.MediaType))
+	fmt.Fprintf(w, "      Digest: %s\n", yellow(descriptor.Digest))
+	fmt.Fprintf(w, "        Size: %s\n", blue(descriptor.Size))
+	fmt.Fprintf(w, "      Schema: %s\n", red("Docker schema 1 (deprecated)"))
+	fmt.Fprintf(w, "          OS: %s\n", green(config.OS))
+	fmt.Fprintf(w, "        Arch: %s\n", green(config.Architecture))
+	if config.Variant != "" {
+		fmt.Fprintf(w, "     Variant: %s\n", green(config.Variant))
+	}
+	fmt.Fprintf(w, "    # Layers: %s\n", red(len(layers)))
+	for i, layer := range layers {
+		fmt.Fprintf(w, "      layer %s: digest = %s\n", red(fmt.Sprintf("%02d", i+1)), yellow(layer))
+	}
+	if verbose {
+		outputStr := strings.Builder{}
+		outputConfig(&outputStr, "", " ", config)
+		fmt.Fprintf(w, "%s", outputStr.String())
+	}
+}
+
+// outputArtifact displays a manifest which is not a container image (or not a
+// known manifest media type at all) by its descriptor and generic manifest fields
+func outputArtifact(w io.Writer, name string, descriptor ocispec.Descriptor, manifest ocispec.Manifest) {
+	var (
+		yellow = color.New(color.Bold, color.FgYellow).SprintFunc()
+		blue   = color.New(color.Bold, color.FgBlue).SprintFunc()
+		green  = color.New(color.Bold, color.FgGreen).SprintFunc()
+	)
+	fmt.Fprintf(w, "Name: %s (Type: %s)\n", green(name), green(descriptor.MediaType))
+	fmt.Fprintf(w, "      Digest: %s\n", yellow(descriptor.Digest))
+	fmt.Fprintf(w, "        Size: %s\n", blue(descriptor.Size))
+	switch descriptor.MediaType {
+	case ocispec.MediaTypeImageManifest, types.MediaTypeDockerSchema2Manifest:
+	default:
+		if manifest.Config.Digest == "" && len(manifest.Layers) == 0 {
+			fmt.Fprintf(w, "Unknown media type for further display: %s\n", descriptor.MediaType)
+			return
+		}
+	}
+	outputStr := strings.Builder{}
+	outputArtifactDetails(&outputStr, "", " ", manifest)
+	fmt.Fprintf(w, "%s", outputStr.String())
+}
+
+// outputArtifactDetails writes the artifact type, config media type, layers and
+// annotations of an artifact manifest, prefixing each line with label
+func outputArtifactDetails(outputStr *strings.Builder, label, indent string, manifest ocispec.Manifest) {
+	var (
+		yellow = color.New(color.Bold, color.FgYellow).SprintFunc()
+		red    = color.New(color.Bold, color.FgRed).SprintFunc()
+		green  = color.New(color.Bold, color.FgGreen).SprintFunc()
+	)
+	artifactType := manifest.ArtifactType
+	if artifactType == "" {
+		// an artifact without an artifactType is identified by its config media type
+		artifactType = manifest.Config.MediaType
+	}
+	outputStr.WriteString(fmt.Sprintf("%s %11s: %s\n", label, "Artifact", green(artifactType)))
+	outputStr.WriteString(fmt.Sprintf("%s %11s: %s\n", label, "Config", green(manifest.Config.MediaType)))
+	if manifest.Subject != nil {
+		outputStr.WriteString(fmt.Sprintf("%s %11s: %s\n", label, "Subject", yellow(manifest.Subject.Digest)))
+	}
+	outputStr.WriteString(fmt.Sprintf("%s %11s: %s\n", label, "# Layers", red(len(manifest.Layers))))
+	for j, layer := range manifest.Layers {
+		outputStr.WriteString(fmt.Sprintf("%s     layer %s: digest = %s\n", indent, red(fmt.Sprintf("%02d", j+1)), yellow(layer.Digest)))
+		outputStr.WriteString(fmt.Sprintf("%s                 type = %s\n", indent, green(layer.MediaType)))
+		if title, ok := layer.Annotations[ocispec.AnnotationTitle]; ok {
+			outputStr.WriteString(fmt.Sprintf("%s                title = %s\n", indent, green(title)))
+		}
+	}
+	var annotations []string
+	for k, v := range manifest.Annotations {
+		annotations = append(annotations, fmt.Sprintf("%s=%s", k, v))
+	}
+	sort.Strings(annotations)
+	if len(annotations) > 0 {
+		outputStr.WriteString(fmt.Sprintf("%s %11s: %s\n", label, "Annotations", green(annotations[0])))
+		for _, a := range annotations[1:] {
+			outputStr.WriteString(fmt.Sprintf("%s %11s  %s\n", label, "", green(a)))
+		}
 	}
 }
 
+// outputConfig writes the details of an image configuration for the verbose
+// human-readable output, prefixing each line with label; the history entries
+// are indented the same way as the layer list of the image
+func outputConfig(outputStr *strings.Builder, label, indent string, config ocispec.Image) {
+	var (
+		yellow = color.New(color.Bold, color.FgYellow).SprintFunc()
+		red    = color.New(color.Bold, color.FgRed).SprintFunc()
+		green  = color.New(color.Bold, color.FgGreen).SprintFunc()
+	)
+	field := func(name string, values ...string) {
+		if len(values) == 0 {
+			return
+		}
+		outputStr.WriteString(fmt.Sprintf("%s %11s: %s\n", label, name, green(values[0])))
+		for _, v := range values[1:] {
+			outputStr.WriteString(fmt.Sprintf("%s %11s  %s\n", label, "", green(v)))
+		}
+	}
+	if config.Created != nil {
+		field("Created", config.Created.UTC().Format(time.RFC3339))
+	}
+	if config.Author != "" {
+		field("Author", config.Author)
+	}
+	if len(config.Config.Entrypoint) > 0 {
+		field("Entrypoint", execForm(config.Config.Entrypoint))
+	}
+	if len(config.Config.Cmd) > 0 {
+		field("Cmd", execForm(config.Config.Cmd))
+	}
+	if config.Config.WorkingDir != "" {
+		field("WorkingDir", config.Config.WorkingDir)
+	}
+	if config.Config.User != "" {
+		field("User", config.Config.User)
+	}
+	field("Env", config.Config.Env...)
+	field("Ports", sortedKeys(config.Config.ExposedPorts)...)
+	field("Volumes", sortedKeys(config.Config.Volumes)...)
+	var labels []string
+	for k, v := range config.Config.Labels {
+		labels = append(labels, fmt.Sprintf("%s=%s", k, v))
+	}
+	sort.Strings(labels)
+	field("Labels", labels...)
+
+	if len(config.History) == 0 {
+		return
+	}
+	outputStr.WriteString(fmt.Sprintf("%s %11s: %s\n", label, "# History", red(len(config.History))))
+	for i, h := range config.History {
+		var empty string
+		if h.EmptyLayer {
+			empty = " (empty layer)"
+		}
+		outputStr.WriteString(fmt.Sprintf("%s     history %s: created_by = %s%s\n", indent, red(fmt.Sprintf("%02d", i+1)), green(h.CreatedBy), yellow(empty)))
+		if h.Created != nil {
+			outputStr.WriteString(fmt.Sprintf("%s                    created = %s\n", indent, green(h.Created.UTC().Format(time.RFC3339))))
+		}
+		if h.Author != "" {
+			outputStr.WriteString(fmt.Sprintf("%s                     author = %s\n", indent, green(h.Author)))
+		}
+		if h.Comment != "" {
+			outputStr.WriteString(fmt.Sprintf("%s                    comment = %s\n", indent, green(h.Comment)))
+		}
+	}
+}
+
+// execForm displays a command line (entrypoint or cmd) in its JSON "exec" form
+func execForm(args []string) string {
+	b, err := json.Marshal(args)
+	if err != nil {
+		return strings.Join(args, " ")
+	}
+	return string(b)
+}
+
+func sortedKeys(m map[string]struct{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 // struct for modeling an index as raw JSON output in a format that
 // includes the same content displayed in human-readable format
+//
+// entries in Manifests are either an ocispec.Manifest or, for an
+// index nested within this index, another indexJson; entries of any
+// other media type are represented by their ocispec.Descriptor
 type indexJson struct {
-	Name          string             `json:"name"`
-	Digest        string             `json:"digest"`
-	SchemaVersion int                `json:"schemaVersion"`
-	MediaType     string             `json:"mediaType,omitempty"`
-	Manifests     []ocispec.Manifest `json:"manifests"`
-	Annotations   map[string]string  `json:"annotations,omitempty"`
+	Name          string            `json:"name,omitempty"`
+	Digest        string            `json:"digest"`
+	SchemaVersion int               `json:"schemaVersion"`
+	MediaType     string            `json:"mediaType,omitempty"`
+	Manifests     []interface{}     `json:"manifests"`
+	Annotations   map[string]string `json:"annotations,omitempty"`
+	Attestations  []attestationJson `json:"attestations,omitempty"`
+}
+
+// struct for modeling the decoded in-toto statements of an attestation manifest
+// as raw JSON output
+type attestationJson struct {
+	Digest     string            `json:"digest"`
+	Reference  string            `json:"reference,omitempty"`
+	Statements []json.RawMessage `json:"statements"`
+}
+
+// struct for modeling a failed image reference inspection in the raw JSON
+// output of a multi-reference inspect
+type inspectErrorJson struct {
+	Name  string `json:"name"`
+	Error string `json:"error"`
 }
 
 // struct for modeling a manifest as raw JSON output in a format that
@@ -207,6 +697,29 @@ type manifestJson struct {
 	ocispec.Manifest
 }
 
+// struct for modeling a legacy Docker schema 1 manifest as raw JSON output; the
+// layers are ordered from the base layer up and the config is synthesized from
+// the v1 image history when expanded
+type schema1Json struct {
+	Name          string          `json:"name"`
+	Digest        string          `json:"digest"`
+	SchemaVersion int             `json:"schemaVersion"`
+	MediaType     string          `json:"mediaType"`
+	Os            string          `json:"os,omitempty"`
+	Arch          string          `json:"architecture,omitempty"`
+	Tag           string          `json:"tag,omitempty"`
+	Layers        []digest.Digest `json:"layers"`
+	Config        *ocispec.Image  `json:"config,omitempty"`
+}
+
+// struct for modeling content of an unknown media type as raw JSON output:
+// its descriptor and, if the content is JSON, the content itself
+type descriptorJson struct {
+	Name string `json:"name"`
+	ocispec.Descriptor
+	Content json.RawMessage `json:"content,omitempty"`
+}
+
 type manifestConfigJson struct {
 	SchemaVersion int                  `json:"schemaVersion"`
 	Name          string               `json:"name"`
@@ -220,36 +733,16 @@ type manifestConfigJson struct {
 	Annotations   map[string]string    `json:"annotations,omitempty"`
 }
 
-func generateRawJSON(name string, descriptor ocispec.Descriptor, expandConfig bool, ms *store.MemoryStore) (string, error) {
+func generateRawJSON(name string, descriptor ocispec.Descriptor, expandConfig, attestations bool, ms store.ContentStore) (string, error) {
 
 	_, db, _ := ms.Get(descriptor)
 	switch descriptor.MediaType {
 	case ocispec.MediaTypeImageIndex, types.MediaTypeDockerSchema2ManifestList:
 		// this is a multi-platform image descriptor; marshal to Index type
-		var idx ocispec.Index
-		if err := json.Unmarshal(db, &idx); err != nil {
+		indexJSON, err := rawIndexJSON(name, descriptor, db, attestations, ms)
+		if err != nil {
 			return "", err
 		}
-		indexJSON := indexJson{
-			Name:          name,
-			Digest:        descriptor.Digest.String(),
-			SchemaVersion: idx.SchemaVersion,
-			MediaType:     idx.MediaType,
-			Annotations:   idx.Annotations,
-		}
-		for _, m := range idx.Manifests {
-			_, man, _ := ms.Get(m)
-			switch m.MediaType {
-			case ocispec.MediaTypeImageManifest, types.MediaTypeDockerSchema2Manifest:
-				var image ocispec.Manifest
-				if err := json.Unmarshal(man, &image); err != nil {
-					return "", err
-				}
-				indexJSON.Manifests = append(indexJSON.Manifests, image)
-			default:
-				return "", fmt.Errorf("unknown media type for further display: %s", m.MediaType)
-			}
-		}
 		b, err := json.MarshalIndent(indexJSON, "", "    ")
 		if err != nil {
 			return "", err
@@ -260,13 +753,15 @@ func generateRawJSON(name string, descriptor ocispec.Descriptor, expandConfig bo
 		if err := json.Unmarshal(db, &man); err != nil {
 			return "", err
 		}
-		_, cb, _ := ms.Get(man.Config)
 		var conf ocispec.Image
-		if err := json.Unmarshal(cb, &conf); err != nil {
-			return "", err
+		if !types.IsArtifactManifest(man) {
+			_, cb, _ := ms.Get(man.Config)
+			if err := json.Unmarshal(cb, &conf); err != nil {
+				return "", err
+			}
 		}
 		var rawJSON interface{}
-		if !expandConfig {
+		if !expandConfig || types.IsArtifactManifest(man) {
 			rawJSON = manifestJson{
 				Name:     name,
 				Digest:   descriptor.Digest.String(),
@@ -293,7 +788,91 @@ func generateRawJSON(name string, descriptor ocispec.Descriptor, expandConfig bo
 			return "", err
 		}
 		return string(b), nil
+	case types.MediaTypeDockerSchema1Manifest, types.MediaTypeDockerSchema1UnsignedManifest:
+		man, v1Images, err := registry.ParseSchema1(db)
+		if err != nil {
+			return "", err
+		}
+		conf := registry.Schema1Config(man, v1Images)
+		rawJSON := schema1Json{
+			Name:          name,
+			Digest:        descriptor.Digest.String(),
+			SchemaVersion: man.SchemaVersion,
+			MediaType:     descriptor.MediaType,
+			Os:            conf.OS,
+			Arch:          conf.Architecture,
+			Tag:           man.Tag,
+			Layers:        registry.Schema1Layers(man, v1Images),
+		}
+		if expandConfig {
+			rawJSON.Config = &conf
+		}
+		b, err := json.MarshalIndent(rawJSON, "", "    ")
+		if err != nil {
+			return "", err
+		}
+		return string(b), nil
 	default:
-		return "", fmt.Errorf("unknown descriptor type: %s", descriptor.MediaType)
+		rawJSON := descriptorJson{
+			Name:       name,
+			Descriptor: descriptor,
+		}
+		if json.Valid(db) {
+			rawJSON.Content = db
+		}
+		b, err := json.MarshalIndent(rawJSON, "", "    ")
+		if err != nil {
+			return "", err
+		}
+		return string(b), nil
+	}
+}
+
+// rawIndexJSON creates the raw JSON model of an index, recursively including
+// the content of any indexes nested within it and, if requested, the decoded
+// statements of its attestations
+func rawIndexJSON(name string, descriptor ocispec.Descriptor, db []byte, attestations bool, ms store.ContentStore) (indexJson, error) {
+	var idx ocispec.Index
+	if err := json.Unmarshal(db, &idx); err != nil {
+		return indexJson{}, err
+	}
+	indexJSON := indexJson{
+		Name:          name,
+		Digest:        descriptor.Digest.String(),
+		SchemaVersion: idx.SchemaVersion,
+		MediaType:     idx.MediaType,
+		Annotations:   idx.Annotations,
+	}
+	for _, m := range idx.Manifests {
+		_, man, _ := ms.Get(m)
+		switch m.MediaType {
+		case ocispec.MediaTypeImageManifest, types.MediaTypeDockerSchema2Manifest:
+			var image ocispec.Manifest
+			if err := json.Unmarshal(man, &image); err != nil {
+				return indexJson{}, err
+			}
+			indexJSON.Manifests = append(indexJSON.Manifests, image)
+			if attestations && m.Annotations["vnd.docker.reference.type"] == "attestation-manifest" {
+				_, statements, err := attestationStatements(ms, image)
+				if err != nil {
+					return indexJson{}, err
+				}
+				indexJSON.Attestations = append(indexJSON.Attestations, attestationJson{
+					Digest:     m.Digest.String(),
+					Reference:  m.Annotations["vnd.docker.reference.digest"],
+					Statements: statements,
+				})
+			}
+		case ocispec.MediaTypeImageIndex, types.MediaTypeDockerSchema2ManifestList:
+			nested, err := rawIndexJSON("", m, man, attestations, ms)
+			if err != nil {
+				return indexJson{}, err
+			}
+			indexJSON.Manifests = append(indexJSON.Manifests, nested)
+		default:
+			// other index entries are represented by their descriptor
+			indexJSON.Manifests = append(indexJSON.Manifests, m)
+		}
 	}
+	return indexJSON, nil
 }
@@ -0,0 +1,321 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/estesp/manifest-tool/v2/pkg/store"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// storeIndex adds an OCI index of the manifests to the content store
+func storeIndex(t *testing.T, cs store.ContentStore, manifests ...ocispec.Descriptor) ocispec.Descriptor {
+	t.Helper()
+	idx := ocispec.Index{MediaType: ocispec.MediaTypeImageIndex, Manifests: manifests}
+	idx.SchemaVersion = 2
+	return storeJSON(t, cs, ocispec.MediaTypeImageIndex, idx)
+}
+
+func TestNestedIndex(t *testing.T) {
+	cs := store.NewMemoryStore()
+	var amd64, arm64 ocispec.Image
+	amd64.OS, amd64.Architecture = "linux", "amd64"
+	arm64.OS, arm64.Architecture = "linux", "arm64"
+	amd64Desc := storeImage(t, cs, amd64, testLayer("amd64", 100))
+	arm64Desc := storeImage(t, cs, arm64, testLayer("arm64", 100))
+	inner := storeIndex(t, cs, arm64Desc)
+	middle := storeIndex(t, cs, inner)
+	top := storeIndex(t, cs, amd64Desc, middle)
+
+	var out strings.Builder
+	attestations, indexes, err := outputManifests(&out, cs, []ocispec.Descriptor{amd64Desc, middle}, "", 0, false, false, newSizeSummary())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attestations != 0 || indexes != 1 {
+		t.Errorf("expected no attestations and 1 nested index at the top level, got %d and %d", attestations, indexes)
+	}
+	for _, want := range []string{
+		"[1]     Type: " + ocispec.MediaTypeImageManifest + "\n",
+		"[1]    -    Arch: amd64\n",
+		"[2]     Type: " + ocispec.MediaTypeImageIndex + "\n",
+		"[2]  Entries: 1 (nested index)\n",
+		"    [2.1]     Type: " + ocispec.MediaTypeImageIndex + "\n",
+		"    [2.1]  Entries: 1 (nested index)\n",
+		"        [2.1.1]     Type: " + ocispec.MediaTypeImageManifest + "\n",
+		"        [2.1.1]   Digest: " + arm64Desc.Digest.String() + "\n",
+		"        [2.1.1]    -    Arch: arm64\n",
+		"             layer 01: digest = ",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("expected %q in the output:\n%s", want, out.String())
+		}
+	}
+
+	_, db, _ := cs.Get(top)
+	indexJSON, err := rawIndexJSON("myreg/app:1.0", top, db, false, cs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if indexJSON.Name != "myreg/app:1.0" || indexJSON.Digest != top.Digest.String() || len(indexJSON.Manifests) != 2 {
+		t.Fatalf("unexpected index %+v", indexJSON)
+	}
+	if man, ok := indexJSON.Manifests[0].(ocispec.Manifest); !ok || man.Layers[0].Digest != testLayer("amd64", 100).Digest {
+		t.Errorf("expected the amd64 image manifest as the first entry, got %+v", indexJSON.Manifests[0])
+	}
+	nested, ok := indexJSON.Manifests[1].(indexJson)
+	if !ok || nested.Name != "" || nested.Digest != middle.Digest.String() || len(nested.Manifests) != 1 {
+		t.Fatalf("expected the nested index as the second entry, got %+v", indexJSON.Manifests[1])
+	}
+	nested, ok = nested.Manifests[0].(indexJson)
+	if !ok || nested.Digest != inner.Digest.String() || len(nested.Manifests) != 1 {
+		t.Fatalf("expected the innermost index in the nested index, got %+v", nested)
+	}
+	if man, ok := nested.Manifests[0].(ocispec.Manifest); !ok || man.Layers[0].Digest != testLayer("arm64", 100).Digest {
+		t.Errorf("expected the arm64 image manifest in the innermost index, got %+v", nested.Manifests[0])
+	}
+}
+
+func TestOutputConfig(t *testing.T) {
+	cs := store.NewMemoryStore()
+	created := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	var config ocispec.Image
+	config.OS, config.Architecture = "linux", "amd64"
+	config.Created = &created
+	config.Config.Entrypoint = []string{"/bin/app", "--serve"}
+	config.Config.Cmd = []string{"--port", "8080"}
+	config.Config.Env = []string{"PATH=/usr/bin", "APP_ENV=prod"}
+	config.Config.Labels = map[string]string{"org.opencontainers.image.version": "1.0", "maintainer": "team"}
+	config.Config.User = "app"
+	config.History = []ocispec.History{
+		{CreatedBy: "ADD rootfs.tar /", Created: &created},
+		{CreatedBy: "ENV APP_ENV=prod", EmptyLayer: true, Comment: "buildkit"},
+	}
+	image := storeImage(t, cs, config, testLayer("rootfs", 100))
+
+	var out strings.Builder
+	if _, _, err := outputManifests(&out, cs, []ocispec.Descriptor{image}, "", 0, true, false, newSizeSummary()); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"[1]     Created: 2024-05-01T12:00:00Z\n",
+		"[1]  Entrypoint: [\"/bin/app\",\"--serve\"]\n",
+		"[1]         Cmd: [\"--port\",\"8080\"]\n",
+		"[1]        User: app\n",
+		"[1]         Env: PATH=/usr/bin\n[1]              APP_ENV=prod\n",
+		"[1]      Labels: maintainer=team\n[1]              org.opencontainers.image.version=1.0\n",
+		"[1]   # History: 2\n",
+		"     history 01: created_by = ADD rootfs.tar /\n",
+		"     history 02: created_by = ENV APP_ENV=prod (empty layer)\n",
+		"                    comment = buildkit\n",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("expected %q in the output:\n%s", want, out.String())
+		}
+	}
+	if strings.Contains(out.String(), "WorkingDir") || strings.Contains(out.String(), "Volumes") {
+		t.Errorf("unexpected output of unset config fields:\n%s", out.String())
+	}
+
+	// an image whose config is not in the content store: it is only read for the verbose output
+	other := store.NewMemoryStore()
+	config.Author = "someone else"
+	missing := storeImage(t, other, config)
+	_, db, _ := other.Get(missing)
+	cs.Set(missing, db)
+	if _, _, err := outputManifests(&strings.Builder{}, cs, []ocispec.Descriptor{missing}, "", 0, false, false, newSizeSummary()); err != nil {
+		t.Errorf("unexpected error without --verbose: %v", err)
+	}
+	_, _, err := outputManifests(&strings.Builder{}, cs, []ocispec.Descriptor{missing}, "", 0, true, false, newSizeSummary())
+	if err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("expected an error for the missing image configuration, got %v", err)
+	}
+}
+
+func TestInspectNames(t *testing.T) {
+	listFile := filepath.Join(t.TempDir(), "images.txt")
+	if err := os.WriteFile(listFile, []byte("# images\nmyreg/a:1\n\n  myreg/b:1  \n#myreg/c:1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var tests = []struct {
+		args     []string
+		listFile string
+		stdin    string
+		want     []string
+	}{
+		{args: []string{"myreg/x:1"}, want: []string{"myreg/x:1"}},
+		{args: []string{"myreg/x:1"}, listFile: listFile, want: []string{"myreg/x:1", "myreg/a:1", "myreg/b:1"}},
+		{listFile: "-", stdin: "myreg/d:1\nmyreg/e:1\n", want: []string{"myreg/d:1", "myreg/e:1"}},
+	}
+	for _, tt := range tests {
+		got, err := inspectNames(tt.args, tt.listFile, strings.NewReader(tt.stdin))
+		if err != nil {
+			t.Errorf("%v %s: unexpected error %v", tt.args, tt.listFile, err)
+		} else if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%v %s: expected %v, got %v", tt.args, tt.listFile, tt.want, got)
+		}
+	}
+	if _, err := inspectNames(nil, filepath.Join(t.TempDir(), "missing.txt"), nil); err == nil {
+		t.Error("expected an error for a missing reference list")
+	}
+}
+
+func TestInspectImages(t *testing.T) {
+	names := []string{"myreg/a:1", "myreg/b:1", "myreg/fail:1", "myreg/d:1"}
+	// earlier references take longer, so they finish out of order
+	var running, maxRunning int32
+	inspect := func(name string) (string, error) {
+		n := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			m := atomic.LoadInt32(&maxRunning)
+			if n <= m || atomic.CompareAndSwapInt32(&maxRunning, m, n) {
+				break
+			}
+		}
+		for i, other := range names {
+			if other == name {
+				time.Sleep(time.Duration(len(names)-i) * 5 * time.Millisecond)
+			}
+		}
+		if strings.Contains(name, "fail") {
+			return "", fmt.Errorf("not found")
+		}
+		return fmt.Sprintf("{\n  \"name\": %q\n}\n", name), nil
+	}
+
+	var tests = []struct {
+		name      string
+		raw       bool
+		jsonLines bool
+		want      string
+	}{
+		{name: "human-readable", want: "{\n  \"name\": \"myreg/a:1\"\n}\n{\n  \"name\": \"myreg/b:1\"\n}\n{\n  \"name\": \"myreg/d:1\"\n}\n"},
+		{name: "json lines", raw: true, jsonLines: true, want: `{"name":"myreg/a:1"}
+{"name":"myreg/b:1"}
+{"name":"myreg/fail:1","error":"not found"}
+{"name":"myreg/d:1"}
+`},
+	}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		err := inspectImages(&out, names, 2, tt.raw, tt.jsonLines, inspect)
+		if err == nil || err.Error() != "inspect failed for 1 of 4 image references" {
+			t.Errorf("%s: expected the failed reference to be reported, got %v", tt.name, err)
+		}
+		if out.String() != tt.want {
+			t.Errorf("%s: expected output\n%s\ngot\n%s", tt.name, tt.want, out.String())
+		}
+	}
+	if maxRunning > 2 {
+		t.Errorf("expected at most 2 concurrent inspections, got %d", maxRunning)
+	}
+
+	// the default raw output is a single JSON array
+	var out bytes.Buffer
+	if err := inspectImages(&out, []string{"myreg/a:1", "myreg/b:1"}, 8, true, false, inspect); err != nil {
+		t.Fatal(err)
+	}
+	var results []map[string]string
+	if err := json.Unmarshal(out.Bytes(), &results); err != nil {
+		t.Fatalf("expected a JSON array, got %v:\n%s", err, out.String())
+	}
+	if len(results) != 2 || results[0]["name"] != "myreg/a:1" || results[1]["name"] != "myreg/b:1" {
+		t.Errorf("unexpected results %v", results)
+	}
+}
+
+func TestOutputArtifact(t *testing.T) {
+	subject := ocispec.Descriptor{MediaType: ocispec.MediaTypeImageManifest, Digest: digest.FromString("image")}
+	wasm := ocispec.Manifest{
+		MediaType:    ocispec.MediaTypeImageManifest,
+		ArtifactType: "application/vnd.wasm.config.v0+json",
+		Config:       ocispec.DescriptorEmptyJSON,
+		Layers: []ocispec.Descriptor{{
+			MediaType:   "application/wasm",
+			Digest:      digest.FromString("module"),
+			Annotations: map[string]string{ocispec.AnnotationTitle: "module.wasm"},
+		}},
+		Subject:     &subject,
+		Annotations: map[string]string{"b": "2", "a": "1"},
+	}
+	helm := ocispec.Manifest{
+		MediaType: ocispec.MediaTypeImageManifest,
+		Config:    ocispec.Descriptor{MediaType: "application/vnd.cncf.helm.config.v1+json", Digest: digest.FromString("chart")},
+	}
+	var tests = []struct {
+		name       string
+		descriptor ocispec.Descriptor
+		manifest   ocispec.Manifest
+		want       []string
+	}{
+		{
+			name:       "artifact type",
+			descriptor: ocispec.Descriptor{MediaType: ocispec.MediaTypeImageManifest, Digest: digest.FromString("wasm"), Size: 400},
+			manifest:   wasm,
+			want: []string{
+				"    Artifact: application/vnd.wasm.config.v0+json\n",
+				"      Config: " + ocispec.MediaTypeEmptyJSON + "\n",
+				"     Subject: " + subject.Digest.String() + "\n",
+				"    # Layers: 1\n",
+				"                 type = application/wasm\n",
+				"                title = module.wasm\n",
+				" Annotations: a=1\n" + strings.Repeat(" ", 14) + "b=2\n",
+			},
+		},
+		{
+			name:       "config media type",
+			descriptor: ocispec.Descriptor{MediaType: ocispec.MediaTypeImageManifest, Digest: digest.FromString("helm"), Size: 300},
+			manifest:   helm,
+			want: []string{
+				"    Artifact: application/vnd.cncf.helm.config.v1+json\n",
+				"    # Layers: 0\n",
+			},
+		},
+		{
+			name:       "unknown media type",
+			descriptor: ocispec.Descriptor{MediaType: "application/vnd.example.thing+json", Digest: digest.FromString("thing"), Size: 13},
+			want:       []string{"Unknown media type for further display: application/vnd.example.thing+json\n"},
+		},
+	}
+	for _, tt := range tests {
+		var out strings.Builder
+		outputArtifact(&out, "myreg/app:1.0", tt.descriptor, tt.manifest)
+		want := append([]string{"Name: myreg/app:1.0 (Type: " + tt.descriptor.MediaType + ")\n", "      Digest: " + tt.descriptor.Digest.String() + "\n"}, tt.want...)
+		for _, w := range want {
+			if !strings.Contains(out.String(), w) {
+				t.Errorf("%s: expected %q in the output:\n%s", tt.name, w, out.String())
+			}
+		}
+	}
+
+	// within an index, an artifact entry is displayed with its artifact details and an
+	// image manifest with its layers and platform
+	cs := store.NewMemoryStore()
+	var config ocispec.Image
+	config.OS, config.Architecture = "linux", "amd64"
+	image := storeImage(t, cs, config, testLayer("rootfs", 100))
+	artifact := storeJSON(t, cs, ocispec.MediaTypeImageManifest, wasm)
+	artifact.Platform = &ocispec.Platform{OS: "wasip1", Architecture: "wasm"}
+	var out strings.Builder
+	if _, _, err := outputManifests(&out, cs, []ocispec.Descriptor{image, artifact}, "", 0, false, false, newSizeSummary()); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"[1] # Layers: 1\n", "[2]    Artifact: application/vnd.wasm.config.v0+json\n", "[2]    -    Arch: wasm\n"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("expected %q in the output:\n%s", want, out.String())
+		}
+	}
+	if strings.Contains(out.String(), "[1]    Artifact") {
+		t.Errorf("the image manifest is displayed as an artifact:\n%s", out.String())
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/estesp/manifest-tool/v2/pkg/registry"
+	"github.com/estesp/manifest-tool/v2/pkg/util"
+
+	"github.com/urfave/cli/v2"
+)
+
+var lintCmd = &cli.Command{
+	Name:      "lint",
+	Usage:     "check a YAML spec for push from-spec without accessing a registry",
+	ArgsUsage: "<spec>",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "type",
+			Value: "docker",
+			Usage: "image manifest type the spec is checked for: docker (v2.2 manifest list), oci (v1 index) or auto",
+		},
+		&cli.StringSliceFlag{
+			Name:  "set",
+			Usage: "set a variable (key=value) for ${VAR} references in the YAML spec, overriding the environment; may be repeated",
+		},
+		&cli.BoolFlag{
+			Name:  "schema",
+			Usage: "print the JSON Schema of the YAML spec instead of checking a spec",
+		},
+	},
+	Action: func(c *cli.Context) error {
+		if c.Bool("schema") {
+			_, err := os.Stdout.Write(util.SpecSchema)
+			return err
+		}
+		filePath := c.Args().First()
+		if filePath == "" {
+			return fmt.Errorf("a YAML spec file must be provided")
+		}
+		yamlInputs, err := readSpec(filePath, c.StringSlice("set"))
+		if err != nil {
+			return err
+		}
+		manifestType, err := parseManifestType(c.String("type"))
+		if err != nil {
+			return err
+		}
+
+		problems := 0
+		for _, yamlInput := range yamlInputs {
+			for _, problem := range registry.Lint(yamlInput, manifestType) {
+				fmt.Printf("%s: %s: %v\n", filePath, yamlInput.Image, problem)
+				problems++
+			}
+		}
+		if problems > 0 {
+			return fmt.Errorf("found %d problem(s) in %s", problems, filePath)
+		}
+		fmt.Printf("%s: %d manifest list definition(s) OK\n", filePath, len(yamlInputs))
+		return nil
+	},
+}
@@ -1,7 +1,9 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
 
@@ -59,6 +61,26 @@ func runApplication() error {
 			Value: util.ConfigDir(),
 			Usage: "either a directory path containing a Docker-formatted config.json or a specific JSON file formatted for registry auth",
 		},
+		&cli.BoolFlag{
+			Name:  "cache",
+			Usage: "keep manifests and configs in a persistent content cache (see --cache-dir) between invocations",
+		},
+		&cli.StringFlag{
+			Name:    "cache-dir",
+			Value:   util.CacheDir(),
+			Usage:   "directory of the persistent content cache; setting it enables the cache",
+			EnvVars: []string{"MANIFEST_TOOL_CACHE_DIR"},
+		},
+		&cli.StringFlag{
+			Name:    "platform-config",
+			Value:   util.PlatformConfigFile(),
+			Usage:   "YAML file of additional operating systems, architectures and variants to accept as valid platforms",
+			EnvVars: []string{"MANIFEST_TOOL_PLATFORM_CONFIG"},
+		},
+		&cli.BoolFlag{
+			Name:  "skip-platform-validation",
+			Usage: "accept any os, architecture and variant in a manifest list/index",
+		},
 	}
 	app.Before = func(c *cli.Context) error {
 		if c.Bool("debug") {
@@ -66,6 +88,17 @@ func runApplication() error {
 		} else {
 			logrus.SetLevel(logrus.WarnLevel)
 		}
+		// the content cache is only used when requested; an empty directory disables it
+		if !c.Bool("cache") && !c.IsSet("cache-dir") {
+			if err := c.Set("cache-dir", ""); err != nil {
+				return fmt.Errorf("unable to update cache-dir flag in context: %w", err)
+			}
+		}
+		util.SkipPlatformValidation(c.Bool("skip-platform-validation"))
+		// a missing platform config file is only an error if it was given explicitly
+		if err := util.LoadPlatformConfig(c.String("platform-config")); err != nil && (c.IsSet("platform-config") || !errors.Is(err, fs.ErrNotExist)) {
+			return fmt.Errorf("failed to load platform config: %w", err)
+		}
 		dockerAuthPath := c.String("docker-cfg")
 		// if set to the default, we don't check for validity because it may not
 		// even exist
@@ -88,10 +121,13 @@ func runApplication() error {
 		}
 		return nil
 	}
-	// currently support inspect and pushml
+	// currently support inspect, resolve, pushml, lint and convert
 	app.Commands = []*cli.Command{
 		inspectCmd,
+		resolveCmd,
 		pushCmd,
+		lintCmd,
+		convertCmd,
 	}
 
 	return app.Run(os.Args)
@@ -1,17 +1,24 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"text/tabwriter"
+	"time"
 
 	"github.com/estesp/manifest-tool/v2/pkg/registry"
+	"github.com/estesp/manifest-tool/v2/pkg/store"
 	"github.com/estesp/manifest-tool/v2/pkg/types"
+	"github.com/estesp/manifest-tool/v2/pkg/util"
 
 	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/sirupsen/logrus"
 	"github.com/urfave/cli/v2"
-	yaml "gopkg.in/yaml.v3"
 )
 
 const (
@@ -27,7 +34,44 @@ var pushCmd = &cli.Command{
 		&cli.StringFlag{
 			Name:  "type",
 			Value: "docker",
-			Usage: "image manifest type: docker (v2.2 manifest list) or oci (v1 index)",
+			Usage: "image manifest type: docker (v2.2 manifest list), oci (v1 index) or auto (oci if OCI-only features such as annotations are used, docker otherwise)",
+		},
+		&cli.BoolFlag{
+			Name:  "keep-nested",
+			Usage: "keep source images which are indexes as nested index entries instead of flattening their manifests into the target (requires --type oci)",
+		},
+		&cli.BoolFlag{
+			Name:  "convert-schema1",
+			Usage: "convert source images with legacy Docker schema 1 manifests into manifests of the target type (retrieves all of their layers)",
+		},
+		&cli.BoolFlag{
+			Name:  "convert-oci",
+			Usage: "rewrite Docker v2 member manifests to OCI media types in the target repository, for an index of only OCI manifests (requires --type oci or auto)",
+		},
+		&cli.BoolFlag{
+			Name:  "drop-annotations",
+			Usage: "drop index and manifest entry annotations (with a warning) when pushing a Docker manifest list, which does not support them, instead of failing",
+		},
+		&cli.StringFlag{
+			Name:  "platform-mismatch",
+			Value: "warn",
+			Usage: "how to handle a manifest entry whose platform does not match its image config: warn, error or override (use the platform of the image config)",
+		},
+		&cli.StringFlag{
+			Name:  "annotations-from-labels",
+			Usage: "add the image config labels which are the same in every member image to the OCI index annotations: \"all\" labels or a comma-separated list of labels",
+		},
+		&cli.StringFlag{
+			Name:  "annotations-from-git",
+			Usage: "add the source, revision and created OCI index annotations from the HEAD commit of the git checkout at this path",
+		},
+		&cli.BoolFlag{
+			Name:  "reproducible",
+			Usage: "build the manifest list/index reproducibly: manifests ordered by platform, canonical JSON and an OCI index created annotation from SOURCE_DATE_EPOCH",
+		},
+		&cli.StringSliceFlag{
+			Name:  "platform-order",
+			Usage: "comma-separated list of platforms (os/arch[/variant]) to order first with --reproducible, instead of only sorting by platform",
 		},
 	},
 	Subcommands: []*cli.Command{
@@ -39,35 +83,77 @@ var pushCmd = &cli.Command{
 					Name:  "ignore-missing",
 					Usage: "only warn on missing images defined in YAML spec",
 				},
+				&cli.BoolFlag{
+					Name:  "continue-on-error",
+					Usage: "continue pushing the remaining manifest lists of a spec with several definitions after a failure",
+				},
+				&cli.StringSliceFlag{
+					Name:  "set",
+					Usage: "set a variable (key=value) for ${VAR} references in the YAML spec, overriding the environment; may be repeated",
+				},
 			},
 			Action: func(c *cli.Context) error {
-				filePath := c.Args().First()
-				var yamlInput types.YAMLInput
-
-				filename, err := filepath.Abs(filePath)
+				yamlInputs, err := readSpec(c.Args().First(), c.StringSlice("set"))
 				if err != nil {
-					return fmt.Errorf(fmtCantResolvePath, filePath, err)
+					return err
 				}
-				yamlFile, err := os.ReadFile(filename)
+
+				manifestType, err := parseManifestType(c.String("type"))
 				if err != nil {
-					return fmt.Errorf(fmtCantReadYAML, filePath, err)
+					return err
 				}
-				err = yaml.Unmarshal(yamlFile, &yamlInput)
+				labels, gitAnnotations, err := indexAnnotationOptions(c, manifestType)
 				if err != nil {
-					return fmt.Errorf(fmtCantUnmarshalYAML, filePath, err)
+					return err
 				}
-
-				manifestType := types.Docker
-				if c.String("type") == "oci" {
-					manifestType = types.OCI
+				opts, err := pushOptions(c, labels)
+				if err != nil {
+					return err
 				}
-				digest, length, err := registry.PushManifestList(c.String("username"), c.String("password"), yamlInput, c.Bool("ignore-missing"), c.Bool("insecure"), c.Bool("plain-http"), manifestType, c.String("docker-cfg"))
+				// all manifest lists of the spec share one content store, so member images
+				// referenced by several of them are only retrieved once
+				memoryStore, err := store.NewCachedMemoryStore(c.String("cache-dir"))
 				if err != nil {
-					return fmt.Errorf("failed to push image: %w", err)
+					return err
 				}
-				fmt.Printf("Digest: %s %d\n", digest, length)
-
-				return nil
+				var results []pushResult
+				for _, yamlInput := range yamlInputs {
+					if c.Bool("keep-nested") {
+						for i := range yamlInput.Manifests {
+							yamlInput.Manifests[i].Nested = true
+						}
+					}
+					if c.Bool("convert-schema1") {
+						for i := range yamlInput.Manifests {
+							yamlInput.Manifests[i].Convert = true
+						}
+					}
+					if c.Bool("convert-oci") {
+						for i := range yamlInput.Manifests {
+							yamlInput.Manifests[i].OCI = true
+						}
+					}
+					addIndexAnnotations(&yamlInput, gitAnnotations)
+					if manifestType == types.Docker && c.Bool("drop-annotations") {
+						dropAnnotations(&yamlInput)
+					}
+					digest, length, err := registry.PushManifestList(c.String("username"), c.String("password"), yamlInput, manifestType, opts, c.String("docker-cfg"), memoryStore)
+					if len(yamlInputs) == 1 {
+						if err != nil {
+							return fmt.Errorf("failed to push image: %w", err)
+						}
+						fmt.Printf("Digest: %s %d\n", digest, length)
+						return nil
+					}
+					if err != nil {
+						logrus.Errorf("failed to push image %s: %v", yamlInput.Image, err)
+					}
+					results = append(results, pushResult{image: yamlInput.Image, digest: digest, length: length, err: err})
+					if err != nil && !c.Bool("continue-on-error") {
+						break
+					}
+				}
+				return printPushSummary(os.Stdout, results, len(yamlInputs))
 			},
 		},
 		{
@@ -81,7 +167,7 @@ var pushCmd = &cli.Command{
 				},
 				&cli.StringFlag{
 					Name:     "template",
-					Usage:    "the pattern the source images have. OS and ARCH in that pattern will be replaced with the actual values from the platforms list",
+					Usage:    "the pattern the source images have. OS, ARCH and VARIANT in that pattern will be replaced with the actual values from the platforms list, or a Go template using {{.OS}}, {{.Arch}} and {{.Variant}}",
 					Required: true,
 				},
 				&cli.StringFlag{
@@ -97,6 +183,10 @@ var pushCmd = &cli.Command{
 					Name:  "annotations",
 					Usage: "additional image annotations to apply to the OCI index, in the form of key=value",
 				},
+				&cli.StringSliceFlag{
+					Name:  "manifest-annotations",
+					Usage: "annotations to apply to the descriptor of the manifest of a platform in the OCI index, in the form of os/arch[/variant]:key=value (or *:key=value for all platforms)",
+				},
 				&cli.BoolFlag{
 					Name:  "ignore-missing",
 					Usage: "only warn on missing images defined in platform list",
@@ -111,24 +201,25 @@ var pushCmd = &cli.Command{
 				srcImages := []types.ManifestEntry{}
 
 				for _, platform := range platforms {
-					osArchArr := strings.Split(platform, "/")
-					if len(osArchArr) != 2 && len(osArchArr) != 3 {
-						return fmt.Errorf("the --platforms argument must be a string slice where one value is of the form 'os/arch'")
+					p, err := types.ParsePlatform(platform)
+					if err != nil {
+						return fmt.Errorf("invalid --platforms value: %w", err)
 					}
-					variant := ""
-					os, arch := osArchArr[0], osArchArr[1]
-					if len(osArchArr) == 3 {
-						variant = osArchArr[2]
+					image, err := util.ImageFromTemplate(templ, p)
+					if err != nil {
+						return fmt.Errorf("invalid --template value: %w", err)
 					}
 					srcImages = append(srcImages, types.ManifestEntry{
-						Image: strings.Replace(strings.Replace(strings.Replace(templ, "ARCH", arch, 1), "OS", os, 1), "VARIANT", variant, 1),
-						Platform: ocispec.Platform{
-							OS:           os,
-							Architecture: arch,
-							Variant:      variant,
-						},
+						Image:    image,
+						Platform: p,
+						Nested:   c.Bool("keep-nested"),
+						Convert:  c.Bool("convert-schema1"),
+						OCI:      c.Bool("convert-oci"),
 					})
 				}
+				if err := addManifestAnnotations(srcImages, c.StringSlice("manifest-annotations")); err != nil {
+					return err
+				}
 				annotationMap := make(map[string]string)
 				for _, annotate := range annotations {
 					parts := strings.Split(annotate, "=")
@@ -143,11 +234,27 @@ var pushCmd = &cli.Command{
 					Manifests:   srcImages,
 					Annotations: annotationMap,
 				}
-				manifestType := types.Docker
-				if c.String("type") == "oci" {
-					manifestType = types.OCI
+				manifestType, err := parseManifestType(c.String("type"))
+				if err != nil {
+					return err
+				}
+				labels, gitAnnotations, err := indexAnnotationOptions(c, manifestType)
+				if err != nil {
+					return err
+				}
+				addIndexAnnotations(&yamlInput, gitAnnotations)
+				if manifestType == types.Docker && c.Bool("drop-annotations") {
+					dropAnnotations(&yamlInput)
+				}
+				opts, err := pushOptions(c, labels)
+				if err != nil {
+					return err
+				}
+				memoryStore, err := store.NewCachedMemoryStore(c.String("cache-dir"))
+				if err != nil {
+					return err
 				}
-				digest, length, err := registry.PushManifestList(c.String("username"), c.String("password"), yamlInput, c.Bool("ignore-missing"), c.Bool("insecure"), c.Bool("plain-http"), manifestType, c.String("docker-cfg"))
+				digest, length, err := registry.PushManifestList(c.String("username"), c.String("password"), yamlInput, manifestType, opts, c.String("docker-cfg"), memoryStore)
 				if err != nil {
 					return fmt.Errorf("pushing image failed: %w", err)
 				}
@@ -158,3 +265,245 @@ var pushCmd = &cli.Command{
 		},
 	},
 }
+
+// addManifestAnnotations adds annotations of the form os/arch[/variant]:key=value to
+// the manifest entry of the platform, or to all entries for the "*" platform
+func addManifestAnnotations(entries []types.ManifestEntry, annotations []string) error {
+	for _, annotation := range annotations {
+		platform, kv, ok := strings.Cut(annotation, ":")
+		key, value, hasValue := strings.Cut(kv, "=")
+		if !ok || !hasValue || key == "" {
+			return fmt.Errorf("the --manifest-annotations argument must be a string in the form 'os/arch[/variant]:key=value': %s", annotation)
+		}
+		var match *ocispec.Platform
+		if platform != "*" {
+			p, err := types.ParsePlatform(platform)
+			if err != nil {
+				return fmt.Errorf("invalid --manifest-annotations platform: %w", err)
+			}
+			match = &p
+		}
+		found := false
+		for i, entry := range entries {
+			if match != nil && (entry.Platform.OS != match.OS || entry.Platform.Architecture != match.Architecture || entry.Platform.Variant != match.Variant) {
+				continue
+			}
+			if entries[i].Annotations == nil {
+				entries[i].Annotations = map[string]string{}
+			}
+			entries[i].Annotations[key] = value
+			found = true
+		}
+		if !found {
+			return fmt.Errorf("the --manifest-annotations platform %s is not one of the --platforms", platform)
+		}
+	}
+	return nil
+}
+
+// dropAnnotations removes the index and manifest entry annotations of the input,
+// which a Docker manifest list does not support
+func dropAnnotations(input *types.YAMLInput) {
+	if len(input.Annotations) > 0 {
+		logrus.Warnf("dropping annotations of %s, which a manifest list (Docker media type) does not support", input.Image)
+		input.Annotations = nil
+	}
+	for i, img := range input.Manifests {
+		if len(img.Annotations) > 0 {
+			logrus.Warnf("dropping annotations of manifest entry %s, which a manifest list (Docker media type) does not support", img.Image)
+			input.Manifests[i].Annotations = nil
+		}
+	}
+}
+
+// parseManifestType parses the --type value into a manifest type
+func parseManifestType(manifestType string) (types.ManifestType, error) {
+	switch manifestType {
+	case "docker":
+		return types.Docker, nil
+	case "oci":
+		return types.OCI, nil
+	case "auto":
+		return types.Auto, nil
+	}
+	return types.Docker, fmt.Errorf("invalid --type value %q: expected docker, oci or auto", manifestType)
+}
+
+// parsePlatformPolicy parses the --platform-mismatch value into a platform policy
+func parsePlatformPolicy(policy string) (types.PlatformPolicy, error) {
+	switch policy {
+	case "warn":
+		return types.PlatformWarn, nil
+	case "error":
+		return types.PlatformError, nil
+	case "override":
+		return types.PlatformOverride, nil
+	}
+	return types.PlatformWarn, fmt.Errorf("invalid --platform-mismatch value %q: expected warn, error or override", policy)
+}
+
+// pushOptions collects the push settings of the command flags, with the labels to
+// promote to index annotations
+func pushOptions(c *cli.Context, labels *types.LabelAnnotations) (types.PushOptions, error) {
+	platformPolicy, err := parsePlatformPolicy(c.String("platform-mismatch"))
+	if err != nil {
+		return types.PushOptions{}, err
+	}
+	reproducible, err := reproducibleOptions(c)
+	if err != nil {
+		return types.PushOptions{}, err
+	}
+	return types.PushOptions{
+		IgnoreMissing:  c.Bool("ignore-missing"),
+		Insecure:       c.Bool("insecure"),
+		PlainHTTP:      c.Bool("plain-http"),
+		PlatformPolicy: platformPolicy,
+		Reproducible:   reproducible,
+		Labels:         labels,
+	}, nil
+}
+
+// reproducibleOptions returns the options of --reproducible, or nil if it is not set
+func reproducibleOptions(c *cli.Context) (*types.Reproducible, error) {
+	if !c.Bool("reproducible") {
+		if c.IsSet("platform-order") {
+			return nil, fmt.Errorf("--platform-order requires --reproducible")
+		}
+		return nil, nil
+	}
+	reproducible := &types.Reproducible{}
+	for _, platform := range c.StringSlice("platform-order") {
+		p, err := types.ParsePlatform(platform)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --platform-order value: %w", err)
+		}
+		reproducible.PlatformOrder = append(reproducible.PlatformOrder, util.NormalizePlatform(p))
+	}
+	if epoch := os.Getenv("SOURCE_DATE_EPOCH"); epoch != "" {
+		seconds, err := strconv.ParseInt(epoch, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid SOURCE_DATE_EPOCH %q: %w", epoch, err)
+		}
+		reproducible.Created = time.Unix(seconds, 0).UTC()
+	}
+	return reproducible, nil
+}
+
+// indexAnnotationOptions returns the options of --annotations-from-labels and the
+// annotations of --annotations-from-git, which are ignored with a warning for a Docker
+// manifest list if --drop-annotations is set
+func indexAnnotationOptions(c *cli.Context, manifestType types.ManifestType) (*types.LabelAnnotations, map[string]string, error) {
+	var (
+		labels         *types.LabelAnnotations
+		gitAnnotations map[string]string
+	)
+	if keys := c.String("annotations-from-labels"); keys != "" {
+		labels = &types.LabelAnnotations{}
+		if keys != "all" {
+			labels.Keys = strings.Split(keys, ",")
+		}
+	}
+	if dir := c.String("annotations-from-git"); dir != "" {
+		var err error
+		gitAnnotations, err = util.GitAnnotations(dir)
+		if err != nil {
+			return nil, nil, fmt.Errorf("unable to read annotations from git checkout %s: %w", dir, err)
+		}
+	}
+	if manifestType == types.Docker && (labels != nil || gitAnnotations != nil) {
+		if !c.Bool("drop-annotations") {
+			return nil, nil, fmt.Errorf("manifest list (Docker media type) does not support annotations from labels or git; use --type oci instead")
+		}
+		logrus.Warn("ignoring --annotations-from-labels and --annotations-from-git, as a manifest list (Docker media type) does not support annotations")
+		return nil, nil, nil
+	}
+	return labels, gitAnnotations, nil
+}
+
+// addIndexAnnotations adds annotations to the index annotations of the input which
+// it does not set already
+func addIndexAnnotations(input *types.YAMLInput, annotations map[string]string) {
+	if len(annotations) == 0 {
+		return
+	}
+	merged := make(map[string]string, len(input.Annotations)+len(annotations))
+	for k, v := range annotations {
+		merged[k] = v
+	}
+	for k, v := range input.Annotations {
+		merged[k] = v
+	}
+	input.Annotations = merged
+}
+
+// readSpec reads and parses the YAML spec file, expanding variables from the
+// key=value settings and the environment
+func readSpec(filePath string, settings []string) ([]types.YAMLInput, error) {
+	vars, err := parseVars(settings)
+	if err != nil {
+		return nil, err
+	}
+	filename, err := filepath.Abs(filePath)
+	if err != nil {
+		return nil, fmt.Errorf(fmtCantResolvePath, filePath, err)
+	}
+	yamlFile, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf(fmtCantReadYAML, filePath, err)
+	}
+	yamlInputs, err := util.ParseYAMLInputs(yamlFile, vars)
+	if err != nil {
+		var specErr *types.SpecError
+		if errors.As(err, &specErr) {
+			return nil, fmt.Errorf("%s:%d:%d: %w", filePath, specErr.Line, specErr.Column, specErr.Err)
+		}
+		return nil, fmt.Errorf(fmtCantUnmarshalYAML, filePath, err)
+	}
+	return yamlInputs, nil
+}
+
+// parseVars parses key=value settings of variables for expansion in a YAML spec
+func parseVars(settings []string) (map[string]string, error) {
+	vars := map[string]string{}
+	for _, setting := range settings {
+		key, value, ok := strings.Cut(setting, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid variable setting %q: expected key=value", setting)
+		}
+		vars[key] = value
+	}
+	return vars, nil
+}
+
+// pushResult is the outcome of pushing one manifest list of a spec
+type pushResult struct {
+	image  string
+	digest string
+	length int
+	err    error
+}
+
+// printPushSummary writes a table of the digest (or failure) of each manifest list
+// pushed from a spec and returns an error if any of them were not pushed
+func printPushSummary(w io.Writer, results []pushResult, total int) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "IMAGE\tDIGEST\tLENGTH") //nolint:errcheck
+	failed := 0
+	for _, r := range results {
+		if r.err != nil {
+			failed++
+			fmt.Fprintf(tw, "%s\tFAILED\t-\n", r.image) //nolint:errcheck
+			continue
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\n", r.image, r.digest, r.length) //nolint:errcheck
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	if skipped := total - len(results); skipped > 0 {
+		return fmt.Errorf("failed to push %d of %d manifest lists (stopped after the first failure; use --continue-on-error to push the rest)", failed+skipped, total)
+	} else if failed > 0 {
+		return fmt.Errorf("failed to push %d of %d manifest lists", failed, total)
+	}
+	return nil
+}
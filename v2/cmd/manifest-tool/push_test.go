@@ -0,0 +1,74 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/estesp/manifest-tool/v2/pkg/types"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+func TestAddManifestAnnotations(t *testing.T) {
+	var tests = []struct {
+		annotations []string
+		want        []map[string]string
+		err         string
+	}{
+		{annotations: nil, want: []map[string]string{nil, nil, nil}},
+		{annotations: []string{"linux/amd64:a=1"}, want: []map[string]string{{"a": "1"}, nil, nil}},
+		{annotations: []string{"linux/arm/v7:a=1", "linux/arm/v7:b="}, want: []map[string]string{nil, nil, {"a": "1", "b": ""}}},
+		{annotations: []string{"*:a=1", "linux/arm64:a=2"}, want: []map[string]string{{"a": "1"}, {"a": "2"}, {"a": "1"}}},
+		{annotations: []string{"linux/s390x:a=1"}, err: "the --manifest-annotations platform linux/s390x is not one of the --platforms"},
+		{annotations: []string{"linux/arm:a=1"}, err: "is not one of the --platforms"},
+		{annotations: []string{"linux/amd64:a"}, err: "must be a string in the form"},
+		{annotations: []string{"a=1"}, err: "must be a string in the form"},
+		{annotations: []string{"linux/amd64:=1"}, err: "must be a string in the form"},
+	}
+	for _, tt := range tests {
+		entries := []types.ManifestEntry{
+			{Image: "myreg/app:amd64", Platform: ocispec.Platform{OS: "linux", Architecture: "amd64"}},
+			{Image: "myreg/app:arm64", Platform: ocispec.Platform{OS: "linux", Architecture: "arm64"}},
+			{Image: "myreg/app:armv7", Platform: ocispec.Platform{OS: "linux", Architecture: "arm", Variant: "v7"}},
+		}
+		err := addManifestAnnotations(entries, tt.annotations)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%v: expected error %q, got %v", tt.annotations, tt.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: unexpected error %v", tt.annotations, err)
+			continue
+		}
+		for i, entry := range entries {
+			if !reflect.DeepEqual(entry.Annotations, tt.want[i]) {
+				t.Errorf("%v: expected annotations %v for %s, got %v", tt.annotations, tt.want[i], entry.Image, entry.Annotations)
+			}
+		}
+	}
+}
+
+func TestDropAnnotations(t *testing.T) {
+	input := types.YAMLInput{
+		Image:       "myreg/app:1.0",
+		Annotations: map[string]string{"org.opencontainers.image.version": "1.0"},
+		Manifests: []types.ManifestEntry{
+			{Image: "myreg/app:amd64", Annotations: map[string]string{"a": "1"}},
+			{Image: "myreg/app:arm64"},
+		},
+	}
+	dropAnnotations(&input)
+	if input.Annotations != nil {
+		t.Errorf("expected no index annotations, got %v", input.Annotations)
+	}
+	for _, entry := range input.Manifests {
+		if entry.Annotations != nil {
+			t.Errorf("expected no annotations for %s, got %v", entry.Image, entry.Annotations)
+		}
+	}
+	if len(input.Manifests) != 2 || input.Image != "myreg/app:1.0" {
+		t.Errorf("unexpected changes to the input %+v", input)
+	}
+}
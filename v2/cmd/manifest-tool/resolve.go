@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/containerd/platforms"
+	"github.com/docker/distribution/reference"
+	"github.com/estesp/manifest-tool/v2/pkg/registry"
+	"github.com/estesp/manifest-tool/v2/pkg/util"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/urfave/cli/v2"
+)
+
+var resolveCmd = &cli.Command{
+	Name:  "resolve",
+	Usage: "resolve image references to their digest, media type and size without fetching manifest content",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "platform",
+			Usage: "resolve the manifest for a specific platform (os/arch[/variant]) of a manifest list/index",
+		},
+		&cli.BoolFlag{
+			Name:  "raw",
+			Usage: "raw JSON output",
+		},
+	},
+	Action: func(c *cli.Context) error {
+		names := c.Args().Slice()
+		if len(names) == 0 {
+			return fmt.Errorf("at least one image reference must be provided")
+		}
+		var platform *ocispec.Platform
+		if c.String("platform") != "" {
+			p, err := platforms.Parse(c.String("platform"))
+			if err != nil {
+				return fmt.Errorf("error parsing platform %q: %w", c.String("platform"), err)
+			}
+			platform = &p
+		}
+
+		var results []resolveJson
+		for _, name := range names {
+			imageRef, err := util.ParseName(name)
+			if err != nil {
+				return fmt.Errorf("error parsing image reference %q: %w", name, err)
+			}
+			if _, ok := imageRef.(reference.NamedTagged); !ok {
+				if _, ok := imageRef.(reference.Digested); !ok {
+					return fmt.Errorf("image reference %q must include a tag or a digest; manifest-tool does not default to 'latest'", name)
+				}
+			}
+			err = util.CreateRegistryHost(imageRef, c.String("username"), c.String("password"), c.Bool("insecure"),
+				c.Bool("plain-http"), c.String("docker-cfg"), false)
+			if err != nil {
+				return fmt.Errorf("error creating registry host configuration: %w", err)
+			}
+			descriptor, err := registry.ResolveDescriptor(util.GetResolver(), imageRef)
+			if err != nil {
+				return fmt.Errorf("error resolving image reference %q: %w", name, err)
+			}
+			result := resolveJson{
+				Name:      name,
+				Digest:    descriptor.Digest.String(),
+				MediaType: descriptor.MediaType,
+				Size:      descriptor.Size,
+			}
+			if platform != nil {
+				platDesc, err := registry.ResolvePlatformDescriptor(util.GetResolver(), imageRef, descriptor, *platform)
+				if err != nil {
+					return fmt.Errorf("error resolving platform %s of image reference %q: %w", platforms.Format(*platform), name, err)
+				}
+				result.Platform = &resolveJson{
+					Name:      platforms.Format(*platform),
+					Digest:    platDesc.Digest.String(),
+					MediaType: platDesc.MediaType,
+					Size:      platDesc.Size,
+				}
+			}
+			if !c.Bool("raw") {
+				fmt.Printf("%s %s %s %d\n", result.Name, result.Digest, result.MediaType, result.Size)
+				if result.Platform != nil {
+					fmt.Printf("%s %s %s %d\n", result.Platform.Name, result.Platform.Digest, result.Platform.MediaType, result.Platform.Size)
+				}
+			}
+			results = append(results, result)
+		}
+		if c.Bool("raw") {
+			var out interface{} = results
+			if len(results) == 1 {
+				out = results[0]
+			}
+			b, err := json.MarshalIndent(out, "", "    ")
+			if err != nil {
+				return fmt.Errorf("error while generating raw JSON output: %w", err)
+			}
+			fmt.Println(string(b))
+		}
+		return nil
+	},
+}
+
+// struct for modeling a resolved image reference (and optionally the
+// manifest resolved for a specific platform) as raw JSON output
+type resolveJson struct {
+	Name      string       `json:"name"`
+	Digest    string       `json:"digest"`
+	MediaType string       `json:"mediaType"`
+	Size      int64        `json:"size"`
+	Platform  *resolveJson `json:"platform,omitempty"`
+}
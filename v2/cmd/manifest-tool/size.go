@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// sizeSummary collects the config and layer blobs referenced by each platform
+// entry of an index to report the unique and shared (compressed) sizes
+type sizeSummary struct {
+	blobs     map[digest.Digest]*blobUsage
+	platforms int
+}
+
+// blobUsage records the size of a blob and the platforms referencing it
+type blobUsage struct {
+	size      int64
+	layer     bool
+	platforms []string
+}
+
+func newSizeSummary() *sizeSummary {
+	return &sizeSummary{
+		blobs: map[digest.Digest]*blobUsage{},
+	}
+}
+
+// add records the config and layers of a platform's image manifest
+func (s *sizeSummary) add(platform string, man ocispec.Manifest) {
+	s.platforms++
+	s.addBlob(platform, man.Config, false)
+	for _, layer := range man.Layers {
+		s.addBlob(platform, layer, true)
+	}
+}
+
+func (s *sizeSummary) addBlob(platform string, desc ocispec.Descriptor, layer bool) {
+	usage, ok := s.blobs[desc.Digest]
+	if !ok {
+		usage = &blobUsage{size: desc.Size, layer: layer}
+		s.blobs[desc.Digest] = usage
+	}
+	for _, p := range usage.platforms {
+		if p == platform {
+			return
+		}
+	}
+	usage.platforms = append(usage.platforms, platform)
+}
+
+// write outputs the index-wide summary: total unique bytes and the layers
+// shared between platforms, largest first
+func (s *sizeSummary) write(outputStr *strings.Builder) {
+	var (
+		yellow = color.New(color.Bold, color.FgYellow).SprintFunc()
+		red    = color.New(color.Bold, color.FgRed).SprintFunc()
+		blue   = color.New(color.Bold, color.FgBlue).SprintFunc()
+		green  = color.New(color.Bold, color.FgGreen).SprintFunc()
+	)
+	if s.platforms == 0 {
+		return
+	}
+	var shared []digest.Digest
+	for d, usage := range s.blobs {
+		if usage.layer && len(usage.platforms) > 1 {
+			shared = append(shared, d)
+		}
+	}
+	sort.Slice(shared, func(i, j int) bool {
+		if s.blobs[shared[i]].size != s.blobs[shared[j]].size {
+			return s.blobs[shared[i]].size > s.blobs[shared[j]].size
+		}
+		return shared[i] < shared[j]
+	})
+	outputStr.WriteString(fmt.Sprintf(" * Size summary for %s platforms (compressed config and layers):\n", red(s.platforms)))
+	outputStr.WriteString(fmt.Sprintf("   -  Unique: %s in %s blobs\n", blue(formatSize(s.total())), red(len(s.blobs))))
+	outputStr.WriteString(fmt.Sprintf("   -  Shared: %s layers\n", red(len(shared))))
+	for _, d := range shared {
+		usage := s.blobs[d]
+		outputStr.WriteString(fmt.Sprintf("      %s: %s\n", yellow(d), blue(formatSize(usage.size))))
+		outputStr.WriteString(fmt.Sprintf("        shared by: %s\n", green(strings.Join(usage.platforms, ", "))))
+	}
+}
+
+// total returns the size of all unique blobs
+func (s *sizeSummary) total() int64 {
+	var total int64
+	for _, usage := range s.blobs {
+		total += usage.size
+	}
+	return total
+}
+
+// imageSize returns the total compressed size of an image: its config and layers
+func imageSize(man ocispec.Manifest) int64 {
+	size := man.Config.Size
+	for _, layer := range man.Layers {
+		size += layer.Size
+	}
+	return size
+}
+
+// formatSize displays a byte count along with a human-readable (SI units) size
+func formatSize(size int64) string {
+	units := []string{"B", "kB", "MB", "GB", "TB"}
+	value := float64(size)
+	i := 0
+	for value >= 1000 && i < len(units)-1 {
+		value /= 1000
+		i++
+	}
+	if i == 0 {
+		return fmt.Sprintf("%d B", size)
+	}
+	return fmt.Sprintf("%d (%.1f %s)", size, value, units[i])
+}
+
+// platformString formats a platform as os/arch[/variant][:os.version] for display
+func platformString(platform *ocispec.Platform) string {
+	if platform == nil {
+		return "unknown"
+	}
+	str := platform.OS + "/" + platform.Architecture
+	if platform.Variant != "" {
+		str += "/" + platform.Variant
+	}
+	if platform.OSVersion != "" {
+		str += ":" + platform.OSVersion
+	}
+	return str
+}
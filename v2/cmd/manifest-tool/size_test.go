@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/estesp/manifest-tool/v2/pkg/store"
+	"github.com/fatih/color"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+func init() {
+	color.NoColor = true
+}
+
+// storeJSON adds the JSON encoding of v to the content store and returns its descriptor
+func storeJSON(t *testing.T, cs store.ContentStore, mediaType string, v interface{}) ocispec.Descriptor {
+	t.Helper()
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	desc := ocispec.Descriptor{MediaType: mediaType, Digest: digest.FromBytes(b), Size: int64(len(b))}
+	if err := cs.Set(desc, b); err != nil {
+		t.Fatal(err)
+	}
+	return desc
+}
+
+// storeImage adds an image manifest with the config and layers to the content store
+func storeImage(t *testing.T, cs store.ContentStore, config ocispec.Image, layers ...ocispec.Descriptor) ocispec.Descriptor {
+	t.Helper()
+	man := ocispec.Manifest{
+		MediaType: ocispec.MediaTypeImageManifest,
+		Config:    storeJSON(t, cs, ocispec.MediaTypeImageConfig, config),
+		Layers:    layers,
+	}
+	man.SchemaVersion = 2
+	desc := storeJSON(t, cs, ocispec.MediaTypeImageManifest, man)
+	desc.Platform = &config.Platform
+	return desc
+}
+
+func testLayer(name string, size int64) ocispec.Descriptor {
+	return ocispec.Descriptor{MediaType: ocispec.MediaTypeImageLayerGzip, Digest: digest.FromString(name), Size: size}
+}
+
+func TestFormatSize(t *testing.T) {
+	var tests = []struct {
+		size int64
+		want string
+	}{
+		{size: 0, want: "0 B"},
+		{size: 999, want: "999 B"},
+		{size: 1000, want: "1000 (1.0 kB)"},
+		{size: 1500, want: "1500 (1.5 kB)"},
+		{size: 999_949, want: "999949 (999.9 kB)"},
+		{size: 1_000_000, want: "1000000 (1.0 MB)"},
+		{size: 2_500_000_000, want: "2500000000 (2.5 GB)"},
+		{size: 1_000_000_000_000, want: "1000000000000 (1.0 TB)"},
+		{size: 5_000_000_000_000_000, want: "5000000000000000 (5000.0 TB)"},
+	}
+	for _, tt := range tests {
+		if got := formatSize(tt.size); got != tt.want {
+			t.Errorf("%d: expected %q, got %q", tt.size, tt.want, got)
+		}
+	}
+}
+
+func TestSizeSummary(t *testing.T) {
+	cs := store.NewMemoryStore()
+	base, app := testLayer("base", 3000), testLayer("app", 200)
+	var amd64, arm64 ocispec.Image
+	amd64.OS, amd64.Architecture = "linux", "amd64"
+	arm64.OS, arm64.Architecture, arm64.Variant = "linux", "arm64", "v8"
+	amd64Desc := storeImage(t, cs, amd64, base, app)
+	arm64Desc := storeImage(t, cs, arm64, base, testLayer("arm64", 100))
+
+	// an attestation manifest, whose blobs do not count towards the image sizes
+	attestation := storeImage(t, cs, ocispec.Image{}, testLayer("provenance", 50_000))
+	attestation.Platform = &ocispec.Platform{OS: "unknown", Architecture: "unknown"}
+	attestation.Annotations = map[string]string{
+		"vnd.docker.reference.type":   "attestation-manifest",
+		"vnd.docker.reference.digest": amd64Desc.Digest.String(),
+	}
+
+	var out strings.Builder
+	sizes := newSizeSummary()
+	attestations, indexes, err := outputManifests(&out, cs, []ocispec.Descriptor{amd64Desc, arm64Desc, attestation}, "", 0, false, false, sizes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attestations != 1 || indexes != 0 {
+		t.Errorf("expected 1 attestation and no indexes, got %d and %d", attestations, indexes)
+	}
+	if sizes.platforms != 2 {
+		t.Errorf("expected 2 platforms, got %d", sizes.platforms)
+	}
+	// the shared base layer is counted once: two configs and three layers
+	var configs int64
+	for _, d := range []ocispec.Descriptor{amd64Desc, arm64Desc} {
+		_, db, _ := cs.Get(d)
+		var man ocispec.Manifest
+		if err := json.Unmarshal(db, &man); err != nil {
+			t.Fatal(err)
+		}
+		configs += man.Config.Size
+	}
+	if len(sizes.blobs) != 5 || sizes.total() != configs+3300 {
+		t.Errorf("expected 5 blobs of %d bytes, got %d of %d", configs+3300, len(sizes.blobs), sizes.total())
+	}
+	if _, ok := sizes.blobs[digest.FromString("provenance")]; ok {
+		t.Error("the attestation layer is included in the size summary")
+	}
+
+	var summary strings.Builder
+	sizes.write(&summary)
+	for _, want := range []string{
+		" * Size summary for 2 platforms",
+		"   -  Shared: 1 layers\n",
+		"      " + base.Digest.String() + ": 3000 (3.0 kB)\n",
+		"        shared by: linux/amd64, linux/arm64/v8\n",
+	} {
+		if !strings.Contains(summary.String(), want) {
+			t.Errorf("expected %q in the summary:\n%s", want, summary.String())
+		}
+	}
+}
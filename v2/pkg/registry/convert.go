@@ -0,0 +1,150 @@
+package registry
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/docker/distribution/reference"
+	"github.com/estesp/manifest-tool/v2/pkg/store"
+	"github.com/estesp/manifest-tool/v2/pkg/types"
+	"github.com/estesp/manifest-tool/v2/pkg/util"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/sirupsen/logrus"
+)
+
+// ConvertManifestList re-encodes the manifest list/index of an image as the other format
+// (an OCI index or a Docker manifest list) and pushes it to the target image, which may
+// be the image itself to convert its tag in place. For a manifest list, OCI manifests
+// are converted to Docker v2 manifests and pushed as well, while the annotations and
+// attestation manifests of an index are dropped with a warning; entries a manifest list
+// cannot hold, such as nested indexes and artifacts, are an error. An image which is already of the
+// manifest type is copied to the target image as it is, keeping its digest, and nothing
+// is pushed if the target is the image itself.
+func ConvertManifestList(username, password, image, target string, manifestType types.ManifestType, insecure, plainHttp bool, configDir string, cs store.ContentStore) (hash string, length int, err error) {
+	if target == "" {
+		target = image
+	}
+	ref, err := util.ParseName(image)
+	if err != nil {
+		return hash, length, fmt.Errorf("unable to parse image reference: %s: %v", image, err)
+	}
+	targetRef, err := util.ParseName(target)
+	if err != nil {
+		return hash, length, fmt.Errorf("unable to parse target image reference: %s: %v", target, err)
+	}
+	if reference.Domain(targetRef) != reference.Domain(ref) {
+		return hash, length, fmt.Errorf("source image (%s) registry does not match target image (%s) registry", ref, targetRef)
+	}
+	if err := util.CreateRegistryHost(targetRef, username, password, insecure, plainHttp, configDir, true); err != nil {
+		return hash, length, fmt.Errorf("error creating registry host configuration: %v", err)
+	}
+	if cs == nil {
+		cs = store.NewMemoryStore()
+	}
+
+	descriptor, err := FetchDescriptor(util.GetResolver(), cs, ref)
+	if err != nil {
+		return hash, length, fmt.Errorf("inspect of image %q failed with error: %v", image, err)
+	}
+	manifestList, err := convertIndex(cs, descriptor, manifestType)
+	if err != nil {
+		return hash, length, fmt.Errorf("unable to convert image %s: %v", image, err)
+	}
+	manifestList.Name = target
+	manifestList.Reference = targetRef
+	manifestList.Resolver = util.GetResolver()
+	if pushRef := reference.Path(ref) != reference.Path(targetRef); pushRef {
+		for i, m := range manifestList.Manifests {
+			if err := setLayerLabels(cs, m.Descriptor); err != nil {
+				return hash, length, err
+			}
+			manifestList.Manifests[i].PushRef = true
+		}
+	}
+	if indexManifestType(descriptor.MediaType) != manifestType {
+		return Push(manifestList, nil, cs)
+	}
+
+	if ref.String() == targetRef.String() {
+		logrus.Warnf("image %s already has the %s manifest type; nothing to convert", image, manifestType)
+		return descriptor.Digest.String(), int(descriptor.Size), nil
+	}
+	// the manifest list/index is copied unchanged rather than rebuilt
+	if err := pushManifestRefs(targetRef, manifestList.Manifests, manifestList.Resolver, cs); err != nil {
+		return hash, length, err
+	}
+	if err := pushIndex(targetRef, descriptor, manifestList.Resolver, cs); err != nil {
+		return hash, length, err
+	}
+	return descriptor.Digest.String(), int(descriptor.Size), nil
+}
+
+// indexManifestType returns the manifest type of a manifest list/index media type, or
+// types.Auto for any other media type
+func indexManifestType(mediaType string) types.ManifestType {
+	switch mediaType {
+	case ocispec.MediaTypeImageIndex:
+		return types.OCI
+	case types.MediaTypeDockerSchema2ManifestList:
+		return types.Docker
+	}
+	return types.Auto
+}
+
+// convertIndex returns the manifest list/index of the manifest type with the entries of
+// the manifest list/index described by desc, which has been fetched into the content
+// store, and for an OCI index its annotations. Converting an OCI index to a Docker
+// manifest list converts its OCI image manifests to Docker v2 manifests, which are to be
+// pushed, and drops its attestation manifests with a warning; other entries which a
+// Docker manifest list cannot hold are an error.
+func convertIndex(cs store.ContentStore, desc ocispec.Descriptor, manifestType types.ManifestType) (types.ManifestList, error) {
+	manifestList := types.ManifestList{Type: manifestType}
+	if indexManifestType(desc.MediaType) == types.Auto {
+		return manifestList, fmt.Errorf("not a manifest list/index (media type %s)", desc.MediaType)
+	}
+	_, db, ok := cs.Get(desc)
+	if !ok {
+		return manifestList, fmt.Errorf("manifest list/index %s not found in the content store", desc.Digest)
+	}
+	var index ocispec.Index
+	if err := json.Unmarshal(db, &index); err != nil {
+		return manifestList, fmt.Errorf("could not unmarshal manifest list/index %s: %v", desc.Digest, err)
+	}
+	if manifestType == types.OCI {
+		manifestList.Annotations = index.Annotations
+	} else if len(index.Annotations) > 0 {
+		logrus.Warnf("dropping annotations of manifest list/index %s, which a manifest list (Docker media type) does not support", desc.Digest)
+	}
+	// the manifests of an OCI index are converted for a manifest list, while a manifest
+	// list is copied as it is
+	toDocker := manifestType == types.Docker && indexManifestType(desc.MediaType) == types.OCI
+	for _, m := range index.Manifests {
+		if toDocker && isAttestationManifest(m) {
+			logrus.Warnf("dropping attestation %s of manifest %s, which a manifest list (Docker media type) does not support", m.Digest, m.Annotations["vnd.docker.reference.digest"])
+			continue
+		}
+		if manifestType == types.Docker {
+			if m.Platform == nil {
+				return manifestList, fmt.Errorf("manifest list (Docker media type) does not support entries without a platform (%s)", m.Digest)
+			}
+			if m.ArtifactType != "" {
+				return manifestList, fmt.Errorf("manifest list (Docker media type) does not support artifact manifest entries (%s)", m.Digest)
+			}
+			switch m.MediaType {
+			case ocispec.MediaTypeImageIndex, types.MediaTypeDockerSchema2ManifestList:
+				return manifestList, fmt.Errorf("manifest list (Docker media type) does not support nested index entries (%s)", m.Digest)
+			}
+			if toDocker && m.MediaType == ocispec.MediaTypeImageManifest {
+				// the Docker v2 manifest is new content for the target repository
+				converted, err := convertToDocker(cs, m)
+				if err != nil {
+					return manifestList, fmt.Errorf("unable to convert manifest %s for a manifest list (Docker media type): %v", m.Digest, err)
+				}
+				manifestList.Manifests = append(manifestList.Manifests, types.Manifest{Descriptor: converted, PushRef: true})
+				continue
+			}
+		}
+		manifestList.Manifests = append(manifestList.Manifests, types.Manifest{Descriptor: m})
+	}
+	return manifestList, nil
+}
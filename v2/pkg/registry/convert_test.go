@@ -0,0 +1,156 @@
+package registry
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/estesp/manifest-tool/v2/pkg/store"
+	"github.com/estesp/manifest-tool/v2/pkg/types"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+func TestConvertIndex(t *testing.T) {
+	cs := store.NewMemoryStore()
+	indexDesc := func(mediaType string, manifests []ocispec.Descriptor, annotations map[string]string) ocispec.Descriptor {
+		idx := ocispec.Index{MediaType: mediaType, Manifests: manifests, Annotations: annotations}
+		idx.SchemaVersion = 2
+		b, _ := json.Marshal(idx)
+		desc := ocispec.Descriptor{MediaType: mediaType, Digest: digest.FromBytes(b), Size: int64(len(b))}
+		cs.Set(desc, b)
+		return desc
+	}
+	image := func(mediaType string, platform *ocispec.Platform) ocispec.Descriptor {
+		man := ocispec.Manifest{
+			MediaType: mediaType,
+			Config:    ocispec.Descriptor{MediaType: ocispec.MediaTypeImageConfig, Digest: digest.FromString(platform.Architecture), Size: 10},
+			Layers:    []ocispec.Descriptor{{MediaType: ocispec.MediaTypeImageLayerGzip, Digest: digest.FromString("layer"), Size: 5}},
+		}
+		if mediaType == types.MediaTypeDockerSchema2Manifest {
+			man.Config.MediaType = types.MediaTypeDockerSchema2Config
+			man.Layers[0].MediaType = types.MediaTypeDockerTarGzipLayer
+		}
+		man.SchemaVersion = 2
+		b, _ := json.Marshal(man)
+		desc := ocispec.Descriptor{MediaType: mediaType, Digest: digest.FromBytes(b), Size: int64(len(b)), Platform: platform}
+		cs.Set(desc, b)
+		return desc
+	}
+	amd64 := image(types.MediaTypeDockerSchema2Manifest, &ocispec.Platform{OS: "linux", Architecture: "amd64"})
+	arm64 := image(ocispec.MediaTypeImageManifest, &ocispec.Platform{OS: "linux", Architecture: "arm64", Variant: "v8"})
+	attestation := ocispec.Descriptor{
+		MediaType:   ocispec.MediaTypeImageManifest,
+		Digest:      digest.FromString("attestation"),
+		Size:        12,
+		Platform:    &ocispec.Platform{OS: "unknown", Architecture: "unknown"},
+		Annotations: map[string]string{"vnd.docker.reference.type": "attestation-manifest", "vnd.docker.reference.digest": arm64.Digest.String()},
+	}
+	annotations := map[string]string{"org.opencontainers.image.version": "1.0"}
+
+	// an OCI index is re-encoded as a manifest list without its annotations and
+	// attestations, and with its OCI manifests converted to Docker v2 manifests
+	desc := indexDesc(ocispec.MediaTypeImageIndex, []ocispec.Descriptor{amd64, arm64, attestation}, annotations)
+	list, err := convertIndex(cs, desc, types.Docker)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if list.Annotations != nil || len(list.Manifests) != 2 {
+		t.Fatalf("unexpected manifest list %+v", list)
+	}
+	if m := list.Manifests[0]; m.Descriptor.Digest != amd64.Digest || m.PushRef {
+		t.Errorf("expected Docker v2 manifest %s to be kept, got %+v", amd64.Digest, m)
+	}
+	if m := list.Manifests[1]; m.Descriptor.Digest == arm64.Digest || !m.PushRef {
+		t.Errorf("expected OCI manifest %s to be converted and pushed, got %+v", arm64.Digest, m)
+	}
+	_, mb, ok := cs.Get(list.Manifests[1].Descriptor)
+	if !ok {
+		t.Fatal("converted manifest is not in the content store")
+	}
+	var man ocispec.Manifest
+	if err := json.Unmarshal(mb, &man); err != nil {
+		t.Fatal(err)
+	}
+	if man.MediaType != types.MediaTypeDockerSchema2Manifest || man.Config.MediaType != types.MediaTypeDockerSchema2Config || man.Layers[0].MediaType != types.MediaTypeDockerTarGzipLayer {
+		t.Errorf("unexpected converted manifest %+v", man)
+	}
+	listDesc, lb, err := buildManifest(list)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var converted ocispec.Index
+	if err := json.Unmarshal(lb, &converted); err != nil {
+		t.Fatal(err)
+	}
+	if listDesc.MediaType != types.MediaTypeDockerSchema2ManifestList || converted.MediaType != types.MediaTypeDockerSchema2ManifestList {
+		t.Errorf("expected a manifest list, got %s", listDesc.MediaType)
+	}
+	for i, want := range []ocispec.Descriptor{amd64, list.Manifests[1].Descriptor} {
+		got := converted.Manifests[i]
+		if got.MediaType != types.MediaTypeDockerSchema2Manifest || got.Digest != want.Digest || got.Size != want.Size || !reflect.DeepEqual(got.Platform, want.Platform) {
+			t.Errorf("entry %d: expected %+v, got %+v", i, want, got)
+		}
+	}
+	if !reflect.DeepEqual(converted.Manifests[1].Platform, arm64.Platform) {
+		t.Errorf("expected the platform of the converted manifest to be kept, got %+v", converted.Manifests[1].Platform)
+	}
+
+	// an OCI manifest which a Docker v2 manifest cannot represent is an error
+	zstd := image(ocispec.MediaTypeImageManifest, &ocispec.Platform{OS: "linux", Architecture: "s390x"})
+	_, zb, _ := cs.Get(zstd)
+	zb = []byte(strings.Replace(string(zb), ocispec.MediaTypeImageLayerGzip, ocispec.MediaTypeImageLayerZstd, 1))
+	zstd.Digest, zstd.Size = digest.FromBytes(zb), int64(len(zb))
+	cs.Set(zstd, zb)
+	if _, err := convertIndex(cs, indexDesc(ocispec.MediaTypeImageIndex, []ocispec.Descriptor{zstd}, nil), types.Docker); err == nil || !strings.Contains(err.Error(), "to a Docker v2 media type") {
+		t.Errorf("expected an error for a zstd layer, got %v", err)
+	}
+
+	// and converted back to an OCI index
+	cs.Set(listDesc, lb)
+	index, err := convertIndex(cs, listDesc, types.OCI)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ociDesc, _, err := buildManifest(index)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ociDesc.MediaType != ocispec.MediaTypeImageIndex || len(index.Manifests) != 2 {
+		t.Errorf("unexpected OCI index %+v", index)
+	}
+
+	// the annotations of an OCI index are kept for an OCI index
+	index, err = convertIndex(cs, desc, types.OCI)
+	if err != nil || index.Annotations["org.opencontainers.image.version"] != "1.0" {
+		t.Errorf("expected the index annotations to be kept, got %v (%v)", index.Annotations, err)
+	}
+
+	nested := indexDesc(ocispec.MediaTypeImageIndex, []ocispec.Descriptor{amd64}, nil)
+	nested.Platform = &ocispec.Platform{OS: "linux", Architecture: "amd64"}
+	artifact := ocispec.Descriptor{MediaType: ocispec.MediaTypeImageManifest, ArtifactType: "application/vnd.example", Digest: digest.FromString("artifact"), Platform: &ocispec.Platform{OS: "linux", Architecture: "amd64"}}
+	noPlatform := ocispec.Descriptor{MediaType: ocispec.MediaTypeImageManifest, Digest: digest.FromString("noplatform")}
+	var tests = []struct {
+		name  string
+		entry ocispec.Descriptor
+		err   string
+	}{
+		{name: "nested index", entry: nested, err: "does not support nested index entries"},
+		{name: "artifact", entry: artifact, err: "does not support artifact manifest entries"},
+		{name: "no platform", entry: noPlatform, err: "does not support entries without a platform"},
+	}
+	for _, tt := range tests {
+		desc := indexDesc(ocispec.MediaTypeImageIndex, []ocispec.Descriptor{amd64, tt.entry}, nil)
+		if _, err := convertIndex(cs, desc, types.Docker); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: expected error %q, got %v", tt.name, tt.err, err)
+		}
+		if _, err := convertIndex(cs, desc, types.OCI); err != nil {
+			t.Errorf("%s: unexpected error converting to an OCI index: %v", tt.name, err)
+		}
+	}
+
+	if _, err := convertIndex(cs, amd64, types.OCI); err == nil || !strings.Contains(err.Error(), "not a manifest list/index") {
+		t.Errorf("expected an error for an image manifest, got %v", err)
+	}
+}
@@ -10,7 +10,7 @@ import (
 	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 )
 
-func FetchDescriptor(resolver remotes.Resolver, memoryStore *store.MemoryStore, imageRef reference.Named) (ocispec.Descriptor, error) {
+func FetchDescriptor(resolver remotes.Resolver, memoryStore store.ContentStore, imageRef reference.Named) (ocispec.Descriptor, error) {
 	return Fetch(context.Background(), memoryStore, types.NewRequest(imageRef, "", allMediaTypes(), resolver))
 }
 
@@ -20,5 +20,25 @@ func allMediaTypes() []string {
 		types.MediaTypeDockerSchema2ManifestList,
 		ocispec.MediaTypeImageManifest,
 		ocispec.MediaTypeImageIndex,
+		types.MediaTypeDockerSchema1Manifest,
 	}
 }
+
+// FetchAttestationContent retrieves the in-toto statements of all attestation manifests
+// in an already fetched image index for decoding and display
+func FetchAttestationContent(resolver remotes.Resolver, memoryStore store.ContentStore, imageRef reference.Named, desc ocispec.Descriptor) error {
+	return FetchAttestations(context.Background(), memoryStore, types.NewRequest(imageRef, "", allMediaTypes(), resolver), desc)
+}
+
+// ResolveDescriptor resolves an image reference to its root descriptor (digest, media type
+// and size) with a single registry request and without retrieving any content
+func ResolveDescriptor(resolver remotes.Resolver, imageRef reference.Named) (ocispec.Descriptor, error) {
+	_, desc, err := resolver.Resolve(context.Background(), imageRef.String())
+	return desc, err
+}
+
+// ResolvePlatformDescriptor resolves the manifest descriptor for a specific platform of the
+// image described by desc, retrieving only the index (or manifest and config) content required
+func ResolvePlatformDescriptor(resolver remotes.Resolver, imageRef reference.Named, desc ocispec.Descriptor, platform ocispec.Platform) (ocispec.Descriptor, error) {
+	return ResolvePlatform(context.Background(), types.NewRequest(imageRef, desc.Digest, allMediaTypes(), resolver), desc, platform)
+}
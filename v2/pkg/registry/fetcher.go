@@ -3,36 +3,77 @@ package registry
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"io"
 
 	ccontent "github.com/containerd/containerd/v2/core/content"
 	"github.com/containerd/containerd/v2/core/images"
 	"github.com/containerd/containerd/v2/core/remotes"
 	"github.com/containerd/containerd/v2/core/remotes/docker"
+	"github.com/containerd/errdefs"
+	"github.com/containerd/platforms"
+	"github.com/docker/distribution/reference"
 	"github.com/estesp/manifest-tool/v2/pkg/store"
 	"github.com/estesp/manifest-tool/v2/pkg/types"
+	"github.com/opencontainers/go-digest"
 	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/sirupsen/logrus"
 )
 
 // Fetch uses a registry (distribution spec) API to retrieve a specific image manifest from a registry
-func Fetch(ctx context.Context, cs *store.MemoryStore, req *types.Request) (ocispec.Descriptor, error) {
+func Fetch(ctx context.Context, cs store.ContentStore, req *types.Request) (ocispec.Descriptor, error) {
 
 	resolver := req.Resolver()
 
-	// Retrieve manifest from registry
-	name, desc, err := resolver.Resolve(ctx, req.Reference().String())
-	if err != nil {
-		return ocispec.Descriptor{}, err
+	var (
+		name string
+		desc ocispec.Descriptor
+		err  error
+	)
+	// content referenced by digest is immutable, so a cached descriptor avoids the registry request
+	digested, isDigested := req.Reference().(reference.Digested)
+	if cache, ok := cs.(store.DescriptorCache); ok && isDigested {
+		if cached, found := cache.CachedDescriptor(digested.Digest()); found {
+			logrus.Debugf("using cached descriptor for %s", req.Reference())
+			name, desc = req.Reference().String(), cached
+		}
+	}
+	if desc.Digest == "" {
+		// Retrieve manifest from registry
+		name, desc, err = resolver.Resolve(ctx, req.Reference().String())
+		if err != nil {
+			return ocispec.Descriptor{}, err
+		}
 	}
 	fetcher, err := resolver.Fetcher(ctx, name)
 	if err != nil {
 		return ocispec.Descriptor{}, err
 	}
+	fetcher = rootFetcher{Fetcher: fetcher, root: desc}
 
 	appendDistSrcLabelHandler, err := docker.AppendDistributionSourceLabel(cs, req.Reference().String())
 	if err != nil {
 		return ocispec.Descriptor{}, err
 	}
 
+	if types.IsSchema1(desc.MediaType) {
+		// containerd no longer fetches schema 1 manifests; as they only reference layers
+		// there is nothing further to walk after retrieving the manifest itself
+		if _, _, found := cs.Get(desc); !found {
+			p, err := fetchBlob(ctx, fetcher, desc)
+			if err != nil {
+				return ocispec.Descriptor{}, err
+			}
+			if err := cs.Set(desc, p); err != nil {
+				return ocispec.Descriptor{}, err
+			}
+		}
+		if _, err := appendDistSrcLabelHandler(ctx, desc); err != nil {
+			return ocispec.Descriptor{}, err
+		}
+		return desc, nil
+	}
+
 	handlers := []images.Handler{
 		remotes.FetchHandler(cs, fetcher),
 		nonLayerChildHandler(cs),
@@ -46,15 +87,30 @@ func Fetch(ctx context.Context, cs *store.MemoryStore, req *types.Request) (ocis
 	return desc, nil
 }
 
+// rootFetcher retrieves the root descriptor of a reference from the manifests endpoint of the
+// registry even when its media type is not a known manifest or index type (e.g. a custom
+// artifact manifest), as the registry only serves it from the endpoint it was resolved from
+type rootFetcher struct {
+	remotes.Fetcher
+	root ocispec.Descriptor
+}
+
+func (f rootFetcher) Fetch(ctx context.Context, desc ocispec.Descriptor) (io.ReadCloser, error) {
+	if desc.Digest == f.root.Digest && !images.IsManifestType(desc.MediaType) && !images.IsIndexType(desc.MediaType) {
+		desc.MediaType = ocispec.MediaTypeImageManifest
+	}
+	return f.Fetcher.Fetch(ctx, desc)
+}
+
 // nonLayerChildHandler returns the immediate children of content described by the descriptor, skipping layers
 // and any other non-manifest/config descriptors. This code is copied and modified (to remove layer retrieval)
 // from the "images.Children" handler in containerd
-func nonLayerChildHandler(provider ccontent.Provider) images.HandlerFunc {
+func nonLayerChildHandler(cs store.ContentStore) images.HandlerFunc {
 	return func(ctx context.Context, desc ocispec.Descriptor) ([]ocispec.Descriptor, error) {
 		var descs []ocispec.Descriptor
 		switch desc.MediaType {
 		case types.MediaTypeDockerSchema2Manifest, ocispec.MediaTypeImageManifest:
-			p, err := ccontent.ReadBlob(ctx, provider, desc)
+			p, err := ccontent.ReadBlob(ctx, cs, desc)
 			if err != nil {
 				return nil, err
 			}
@@ -65,7 +121,7 @@ func nonLayerChildHandler(provider ccontent.Provider) images.HandlerFunc {
 
 			descs = append(descs, manifest.Config)
 		case types.MediaTypeDockerSchema2ManifestList, ocispec.MediaTypeImageIndex:
-			p, err := ccontent.ReadBlob(ctx, provider, desc)
+			p, err := ccontent.ReadBlob(ctx, cs, desc)
 			if err != nil {
 				return nil, err
 			}
@@ -79,12 +135,8 @@ func nonLayerChildHandler(provider ccontent.Provider) images.HandlerFunc {
 			// we want to save the descriptor info about layers in our content store
 			// in case we are going to handle push of a manifest list (will need to handle
 			// have the details for doing blob mounting on manifest list/index push)
-			cs, ok := provider.(*store.MemoryStore)
-			if ok {
-				// a slight hack to get the info about layers without the actual layer content
-				// into our content store
-				cs.Set(desc, []byte{})
-			}
+			// layer content is never retrieved, so only the descriptor is recorded
+			cs.SetDescriptor(desc)
 		default:
 			// if we aren't at a manifest or index/manifestlist then we can stop walking
 			return nil, nil
@@ -93,3 +145,144 @@ func nonLayerChildHandler(provider ccontent.Provider) images.HandlerFunc {
 		return descs, nil
 	}
 }
+
+// FetchAttestations retrieves the layer content (in-toto statements) of each attestation manifest
+// referenced by an index which has already been fetched into the content store, including those of
+// any nested indexes. Layer content is otherwise never retrieved by manifest-tool.
+func FetchAttestations(ctx context.Context, cs store.ContentStore, req *types.Request, desc ocispec.Descriptor) error {
+	layers, err := attestationLayers(ctx, cs, desc)
+	if err != nil {
+		return err
+	}
+	if len(layers) == 0 {
+		return nil
+	}
+	fetcher, err := req.Resolver().Fetcher(ctx, req.Reference().String())
+	if err != nil {
+		return err
+	}
+	return images.Dispatch(ctx, remotes.FetchHandler(cs, fetcher), nil, layers...)
+}
+
+func attestationLayers(ctx context.Context, provider ccontent.Provider, desc ocispec.Descriptor) ([]ocispec.Descriptor, error) {
+	switch desc.MediaType {
+	case types.MediaTypeDockerSchema2ManifestList, ocispec.MediaTypeImageIndex:
+	default:
+		return nil, nil
+	}
+	p, err := ccontent.ReadBlob(ctx, provider, desc)
+	if err != nil {
+		return nil, err
+	}
+	var index ocispec.Index
+	if err := json.Unmarshal(p, &index); err != nil {
+		return nil, err
+	}
+	var layers []ocispec.Descriptor
+	for _, m := range index.Manifests {
+		if !isAttestationManifest(m) {
+			nested, err := attestationLayers(ctx, provider, m)
+			if err != nil {
+				return nil, err
+			}
+			layers = append(layers, nested...)
+			continue
+		}
+		p, err := ccontent.ReadBlob(ctx, provider, m)
+		if err != nil {
+			return nil, err
+		}
+		var manifest ocispec.Manifest
+		if err := json.Unmarshal(p, &manifest); err != nil {
+			return nil, err
+		}
+		layers = append(layers, manifest.Layers...)
+	}
+	return layers, nil
+}
+
+// ResolvePlatform selects the manifest matching the platform from the index described by desc,
+// descending into nested indexes. Only the index content is retrieved; if desc is an image manifest
+// its config is retrieved to verify that it provides the requested platform.
+func ResolvePlatform(ctx context.Context, req *types.Request, desc ocispec.Descriptor, platform ocispec.Platform) (ocispec.Descriptor, error) {
+	fetcher, err := req.Resolver().Fetcher(ctx, req.Reference().String())
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+	matcher := platforms.NewMatcher(platform)
+	switch desc.MediaType {
+	case types.MediaTypeDockerSchema2Manifest, ocispec.MediaTypeImageManifest:
+		p, err := fetchBlob(ctx, fetcher, desc)
+		if err != nil {
+			return ocispec.Descriptor{}, err
+		}
+		var manifest ocispec.Manifest
+		if err := json.Unmarshal(p, &manifest); err != nil {
+			return ocispec.Descriptor{}, err
+		}
+		p, err = fetchBlob(ctx, fetcher, manifest.Config)
+		if err != nil {
+			return ocispec.Descriptor{}, err
+		}
+		var config ocispec.Image
+		if err := json.Unmarshal(p, &config); err != nil {
+			return ocispec.Descriptor{}, err
+		}
+		if !matcher.Match(config.Platform) {
+			return ocispec.Descriptor{}, fmt.Errorf("image manifest %s is for platform %s, not %s: %w", desc.Digest, platforms.Format(config.Platform), platforms.Format(platform), errdefs.ErrNotFound)
+		}
+		desc.Platform = &config.Platform
+		return desc, nil
+	case types.MediaTypeDockerSchema2ManifestList, ocispec.MediaTypeImageIndex:
+		p, err := fetchBlob(ctx, fetcher, desc)
+		if err != nil {
+			return ocispec.Descriptor{}, err
+		}
+		var index ocispec.Index
+		if err := json.Unmarshal(p, &index); err != nil {
+			return ocispec.Descriptor{}, err
+		}
+		for _, m := range index.Manifests {
+			if isAttestationManifest(m) {
+				continue
+			}
+			switch m.MediaType {
+			case types.MediaTypeDockerSchema2ManifestList, ocispec.MediaTypeImageIndex:
+				if m.Platform != nil && !matcher.Match(*m.Platform) {
+					continue
+				}
+				match, err := ResolvePlatform(ctx, req, m, platform)
+				if err == nil {
+					return match, nil
+				}
+				if !errdefs.IsNotFound(err) {
+					return ocispec.Descriptor{}, err
+				}
+			default:
+				if m.Platform != nil && matcher.Match(*m.Platform) {
+					return m, nil
+				}
+			}
+		}
+		return ocispec.Descriptor{}, fmt.Errorf("no manifest for platform %s in index %s: %w", platforms.Format(platform), desc.Digest, errdefs.ErrNotFound)
+	default:
+		return ocispec.Descriptor{}, fmt.Errorf("cannot select a platform from media type %s", desc.MediaType)
+	}
+}
+
+// fetchBlob retrieves and verifies the content of a descriptor without storing it
+func fetchBlob(ctx context.Context, fetcher remotes.Fetcher, desc ocispec.Descriptor) ([]byte, error) {
+	rc, err := fetcher.Fetch(ctx, desc)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close() //nolint:errcheck
+	p, err := io.ReadAll(io.LimitReader(rc, desc.Size+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(p)) != desc.Size || digest.FromBytes(p) != desc.Digest {
+		return nil, fmt.Errorf("content of %s does not match its descriptor: %w", desc.Digest, errdefs.ErrFailedPrecondition)
+	}
+	return p, nil
+}
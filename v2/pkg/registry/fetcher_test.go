@@ -0,0 +1,118 @@
+package registry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/containerd/containerd/v2/core/remotes"
+	"github.com/containerd/errdefs"
+	"github.com/containerd/platforms"
+	"github.com/docker/distribution/reference"
+	"github.com/estesp/manifest-tool/v2/pkg/store"
+	"github.com/estesp/manifest-tool/v2/pkg/types"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// storeResolver serves the content of a content store as a registry would
+type storeResolver struct {
+	cs *store.MemoryStore
+}
+
+func (r storeResolver) Resolve(ctx context.Context, ref string) (string, ocispec.Descriptor, error) {
+	return "", ocispec.Descriptor{}, errdefs.ErrNotImplemented
+}
+
+func (r storeResolver) Fetcher(ctx context.Context, ref string) (remotes.Fetcher, error) {
+	return remotes.FetcherFunc(func(ctx context.Context, desc ocispec.Descriptor) (io.ReadCloser, error) {
+		_, b, ok := r.cs.Get(desc)
+		if !ok {
+			return nil, errdefs.ErrNotFound
+		}
+		return io.NopCloser(bytes.NewReader(b)), nil
+	}), nil
+}
+
+func (r storeResolver) Pusher(ctx context.Context, ref string) (remotes.Pusher, error) {
+	return nil, errdefs.ErrNotImplemented
+}
+
+func TestResolvePlatform(t *testing.T) {
+	cs := store.NewMemoryStore()
+	set := func(mediaType string, v interface{}) ocispec.Descriptor {
+		b, _ := json.Marshal(v)
+		desc := ocispec.Descriptor{MediaType: mediaType, Digest: digest.FromBytes(b), Size: int64(len(b))}
+		cs.Set(desc, b)
+		return desc
+	}
+	image := func(platform string) ocispec.Descriptor {
+		var config types.Image
+		config.Platform = platforms.MustParse(platform)
+		man := ocispec.Manifest{MediaType: ocispec.MediaTypeImageManifest, Config: set(ocispec.MediaTypeImageConfig, config)}
+		desc := set(ocispec.MediaTypeImageManifest, man)
+		p := config.Platform
+		desc.Platform = &p
+		return desc
+	}
+	index := func(manifests ...ocispec.Descriptor) ocispec.Descriptor {
+		return set(ocispec.MediaTypeImageIndex, ocispec.Index{MediaType: ocispec.MediaTypeImageIndex, Manifests: manifests})
+	}
+	attestation := func(m ocispec.Descriptor) ocispec.Descriptor {
+		a := image("linux/amd64")
+		a.Annotations = map[string]string{
+			"vnd.docker.reference.type":   "attestation-manifest",
+			"vnd.docker.reference.digest": m.Digest.String(),
+		}
+		return a
+	}
+
+	amd64 := image("linux/amd64")
+	arm64 := image("linux/arm64")
+	arm64v8 := arm64
+	arm64v8.Platform = &ocispec.Platform{OS: "linux", Architecture: "arm64", Variant: "v8"}
+	armv7 := image("linux/arm/v7")
+
+	var tests = []struct {
+		name     string
+		desc     ocispec.Descriptor
+		platform string
+		want     ocispec.Descriptor
+		err      string
+	}{
+		{name: "exact", desc: index(amd64, arm64, armv7), platform: "linux/arm64", want: arm64},
+		{name: "default variant requested", desc: index(amd64, arm64), platform: "linux/arm64/v8", want: arm64},
+		{name: "default variant in index", desc: index(amd64, arm64v8), platform: "linux/arm64", want: arm64v8},
+		{name: "alias", desc: index(amd64, arm64), platform: "linux/aarch64", want: arm64},
+		{name: "other variant", desc: index(amd64, armv7), platform: "linux/arm/v6", err: "no manifest for platform linux/arm/v6"},
+		{name: "no match", desc: index(amd64, arm64), platform: "linux/s390x", err: "no manifest for platform linux/s390x"},
+		{name: "only attestations", desc: index(attestation(amd64), attestation(arm64)), platform: "linux/amd64", err: "no manifest for platform linux/amd64"},
+		{name: "nested", desc: index(amd64, index(armv7, arm64)), platform: "linux/arm64", want: arm64},
+		{name: "image manifest", desc: amd64, platform: "linux/amd64", want: amd64},
+		{name: "image manifest of another platform", desc: amd64, platform: "linux/arm64", err: "is for platform linux/amd64, not linux/arm64"},
+	}
+	ref, _ := reference.ParseNormalizedNamed("myreg/app:1.0")
+	req := types.NewRequest(ref, "", allMediaTypes(), storeResolver{cs: cs})
+	for _, tt := range tests {
+		// the platform is passed as written, without containerd's normalization
+		platform, err := types.ParsePlatform(tt.platform)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := ResolvePlatform(context.Background(), req, tt.desc, platform)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) || !errdefs.IsNotFound(err) {
+				t.Errorf("%s: expected a not found error %q, got %v", tt.name, tt.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+		} else if got.Digest != tt.want.Digest {
+			t.Errorf("%s: expected manifest %s, got %s", tt.name, tt.want.Digest, got.Digest)
+		}
+	}
+}
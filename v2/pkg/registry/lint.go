@@ -0,0 +1,83 @@
+package registry
+
+import (
+	"fmt"
+
+	"github.com/containerd/platforms"
+	"github.com/docker/distribution/reference"
+	"github.com/estesp/manifest-tool/v2/pkg/types"
+	"github.com/estesp/manifest-tool/v2/pkg/util"
+)
+
+// Lint runs the checks of a manifest list/index push which do not require a registry
+// on its input, and returns every problem found: invalid image references or tags,
+// source images in another registry than the target, unsupported or duplicate
+// platforms and features the manifest type does not support. Platforms which are
+// taken from the image configs of the source images are not checked.
+func Lint(input types.YAMLInput, manifestType types.ManifestType) []error {
+	var problems []error
+	if manifestType == types.Auto {
+		manifestType = autoManifestType(input, nil)
+	}
+	targetRef, err := reference.ParseNormalizedNamed(input.Image)
+	if err != nil {
+		problems = append(problems, fmt.Errorf("invalid target image reference %q: %v", input.Image, err))
+	} else {
+		for _, tag := range input.Tags {
+			if _, err := reference.WithTag(targetRef, tag); err != nil {
+				problems = append(problems, fmt.Errorf("invalid tag %q: %v", tag, err))
+			}
+		}
+	}
+	if manifestType == types.Docker && len(input.Annotations) > 0 {
+		problems = append(problems, fmt.Errorf("manifest list (Docker media type) does not support annotations; use --type oci instead"))
+	}
+	if len(input.Manifests) == 0 {
+		problems = append(problems, fmt.Errorf("no manifest entries"))
+	}
+
+	seen := map[string]string{}
+	for _, img := range input.Manifests {
+		ref, err := util.ParseName(img.Image)
+		if err != nil {
+			problems = append(problems, fmt.Errorf("invalid image reference %q: %v", img.Image, err))
+		} else if targetRef != nil && reference.Domain(targetRef) != reference.Domain(ref) {
+			problems = append(problems, fmt.Errorf("source image (%s) registry does not match target image (%s) registry", ref, targetRef))
+		}
+		if len(img.Annotations) > 0 && manifestType == types.Docker {
+			problems = append(problems, fmt.Errorf("manifest list (Docker media type) does not support annotations on manifest entries (%s); use --type oci instead", img.Image))
+		}
+		if _, err := parseSelectors(img.Platforms); err != nil {
+			problems = append(problems, fmt.Errorf("invalid platforms for image %s: %v", img.Image, err))
+		} else if img.Nested && len(img.Platforms) > 0 {
+			problems = append(problems, fmt.Errorf("manifest entry for image %s cannot select platforms of a nested index", img.Image))
+		}
+		if img.OCI && manifestType == types.Docker {
+			problems = append(problems, fmt.Errorf("manifest entry for image %s is converted to OCI, which requires an OCI index; use --type oci instead", img.Image))
+		}
+		if img.OCI && img.Nested {
+			problems = append(problems, fmt.Errorf("manifest entry for image %s cannot convert a nested index to OCI", img.Image))
+		}
+		if img.Nested && manifestType == types.Docker {
+			problems = append(problems, fmt.Errorf("manifest list (Docker media type) does not support nested index entries (%s); use --type oci instead", img.Image))
+		}
+		platform := util.NormalizePlatform(img.Platform)
+		if platform.OS == "" && platform.Architecture == "" {
+			continue
+		}
+		if !util.IsValidOSArch(platform.OS, platform.Architecture, platform.Variant) {
+			problems = append(problems, fmt.Errorf("manifest entry for image %s has unsupported os/arch or os/arch/variant combination: %s/%s/%s", img.Image, platform.OS, platform.Architecture, platform.Variant))
+		}
+		if platform.OS == "windows" && platform.OSVersion == "" {
+			// Windows images are usually told apart by the OS version in their image config
+			continue
+		}
+		platStr := getPlatformString(&platform)
+		if other, ok := seen[platStr]; ok {
+			problems = append(problems, fmt.Errorf("images %s and %s both provide platform %s", other, img.Image, platforms.Format(platform)))
+			continue
+		}
+		seen[platStr] = img.Image
+	}
+	return problems
+}
@@ -0,0 +1,87 @@
+package registry
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/estesp/manifest-tool/v2/pkg/types"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+func TestLint(t *testing.T) {
+	entry := func(image, os, arch, variant string) types.ManifestEntry {
+		return types.ManifestEntry{Image: image, Platform: ocispec.Platform{OS: os, Architecture: arch, Variant: variant}}
+	}
+	var tests = []struct {
+		name         string
+		input        types.YAMLInput
+		manifestType types.ManifestType
+		problems     []string
+	}{
+		{
+			name: "valid",
+			input: types.YAMLInput{
+				Image: "myreg.io/app:1",
+				Tags:  []string{"latest"},
+				Manifests: []types.ManifestEntry{
+					entry("myreg.io/app:amd64", "linux", "amd64", ""),
+					entry("myreg.io/app:arm64", "linux", "arm64", "v8"),
+					entry("myreg.io/app:any", "", "", ""),
+					entry("myreg.io/app:win2019", "windows", "amd64", ""),
+					entry("myreg.io/app:win2022", "windows", "amd64", ""),
+				},
+			},
+			manifestType: types.Docker,
+		},
+		{
+			name: "problems",
+			input: types.YAMLInput{
+				Image:       "myreg.io/app:1",
+				Tags:        []string{"not a tag"},
+				Annotations: map[string]string{"a": "b"},
+				Manifests: []types.ManifestEntry{
+					entry("myreg.io/app:amd64", "linux", "amd64", ""),
+					entry("other.io/app:amd64", "linux", "amd64", ""),
+					entry("myreg.io/app:arm", "linux", "arm", "v9"),
+					{Image: "myreg.io/app:nested", Nested: true},
+					entry("myreg.io/APP:bad", "", "", ""),
+					{Image: "myreg.io/app:annotated", Annotations: map[string]string{"a": "b"}},
+					{Image: "myreg.io/app:selected", Platforms: []string{"linux"}},
+				},
+			},
+			manifestType: types.Docker,
+			problems: []string{
+				`invalid tag "not a tag"`,
+				"does not support annotations",
+				"registry does not match target image",
+				"images myreg.io/app:amd64 and other.io/app:amd64 both provide platform linux/amd64",
+				"unsupported os/arch or os/arch/variant combination: linux/arm/v9",
+				"does not support nested index entries",
+				`invalid image reference "myreg.io/APP:bad"`,
+				"does not support annotations on manifest entries (myreg.io/app:annotated)",
+				`invalid platforms for image myreg.io/app:selected: invalid platform "linux"`,
+			},
+		},
+		{
+			name: "oci",
+			input: types.YAMLInput{
+				Image:       "myreg.io/app:1",
+				Annotations: map[string]string{"a": "b"},
+				Manifests:   []types.ManifestEntry{{Image: "myreg.io/app:nested", Nested: true, Annotations: map[string]string{"a": "b"}}},
+			},
+			manifestType: types.OCI,
+		},
+	}
+	for _, tt := range tests {
+		problems := Lint(tt.input, tt.manifestType)
+		if len(problems) != len(tt.problems) {
+			t.Errorf("%s: expected %d problems, got %v", tt.name, len(tt.problems), problems)
+			continue
+		}
+		for i, problem := range problems {
+			if !strings.Contains(problem.Error(), tt.problems[i]) {
+				t.Errorf("%s: expected problem %q, got %v", tt.name, tt.problems[i], problem)
+			}
+		}
+	}
+}
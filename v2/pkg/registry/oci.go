@@ -0,0 +1,142 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/estesp/manifest-tool/v2/pkg/store"
+	"github.com/estesp/manifest-tool/v2/pkg/types"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/sirupsen/logrus"
+)
+
+// ociMediaTypes maps the Docker v2 media types of image configs and layers to the
+// corresponding OCI media types
+var ociMediaTypes = map[string]string{
+	types.MediaTypeDockerSchema2Config:                          ocispec.MediaTypeImageConfig,
+	types.MediaTypeDockerTarGzipLayer:                           ocispec.MediaTypeImageLayerGzip,
+	"application/vnd.docker.image.rootfs.diff.tar":              ocispec.MediaTypeImageLayer,
+	"application/vnd.docker.image.rootfs.foreign.diff.tar.gzip": ocispec.MediaTypeImageLayerNonDistributableGzip, //nolint:staticcheck
+	"application/vnd.docker.image.rootfs.foreign.diff.tar":      ocispec.MediaTypeImageLayerNonDistributable,     //nolint:staticcheck
+}
+
+// convertToOCI rewrites a Docker v2 image manifest to an OCI image manifest with the OCI
+// media types of its config and layers, which keep their content and digests. The new
+// manifest is stored in the content store with the distribution source labels of the
+// original, so its layers can be mounted from the source repository on push. An OCI
+// manifest is returned as it is.
+func convertToOCI(cs store.ContentStore, desc ocispec.Descriptor) (ocispec.Descriptor, error) {
+	if desc.MediaType != types.MediaTypeDockerSchema2Manifest {
+		return desc, nil
+	}
+	converted, err := rewriteManifest(cs, desc, ocispec.MediaTypeImageManifest, convertMediaType)
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+	logrus.Infof("converted Docker v2 manifest %s to OCI manifest %s", desc.Digest, converted.Digest)
+	return converted, nil
+}
+
+// convertToDocker rewrites an OCI image manifest to a Docker v2 image manifest, as
+// convertToOCI does the other way around. A manifest which a Docker v2 manifest cannot
+// represent, such as one with zstd layers or a subject, is an error. A Docker v2
+// manifest is returned as it is.
+func convertToDocker(cs store.ContentStore, desc ocispec.Descriptor) (ocispec.Descriptor, error) {
+	if desc.MediaType != ocispec.MediaTypeImageManifest {
+		return desc, nil
+	}
+	converted, err := rewriteManifest(cs, desc, types.MediaTypeDockerSchema2Manifest, convertDockerMediaType)
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+	logrus.Infof("converted OCI manifest %s to Docker v2 manifest %s", desc.Digest, converted.Digest)
+	return converted, nil
+}
+
+// rewriteManifest stores a copy of the manifest described by desc with the manifest
+// media type and the media types of its config and layers converted, and returns the
+// descriptor of the copy, which takes the labels of the original
+func rewriteManifest(cs store.ContentStore, desc ocispec.Descriptor, mediaType string, convert func(*ocispec.Descriptor) error) (ocispec.Descriptor, error) {
+	_, db, ok := cs.Get(desc)
+	if !ok {
+		return ocispec.Descriptor{}, fmt.Errorf("manifest %s not found in the content store", desc.Digest)
+	}
+	var man ocispec.Manifest
+	if err := json.Unmarshal(db, &man); err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("could not unmarshal manifest %s: %v", desc.Digest, err)
+	}
+	if man.Subject != nil && mediaType == types.MediaTypeDockerSchema2Manifest {
+		return ocispec.Descriptor{}, fmt.Errorf("cannot convert manifest %s with a subject to a Docker v2 manifest", desc.Digest)
+	}
+	man.MediaType = mediaType
+	if err := convert(&man.Config); err != nil {
+		return ocispec.Descriptor{}, err
+	}
+	for i := range man.Layers {
+		if err := convert(&man.Layers[i]); err != nil {
+			return ocispec.Descriptor{}, err
+		}
+	}
+	mb, err := json.MarshalIndent(man, "", "  ")
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+	converted := desc
+	converted.MediaType = mediaType
+	converted.Digest = digest.FromBytes(mb)
+	converted.Size = int64(len(mb))
+	if err := cs.Set(converted, mb); err != nil {
+		return ocispec.Descriptor{}, err
+	}
+
+	info, err := cs.Info(context.TODO(), desc.Digest)
+	if err == nil {
+		info.Digest = converted.Digest
+		if _, err := cs.Update(context.TODO(), info); err != nil {
+			return ocispec.Descriptor{}, err
+		}
+	}
+	return converted, nil
+}
+
+func convertMediaType(desc *ocispec.Descriptor) error {
+	switch desc.MediaType {
+	case ocispec.MediaTypeImageConfig, ocispec.MediaTypeImageLayer, ocispec.MediaTypeImageLayerGzip, ocispec.MediaTypeImageLayerZstd:
+		return nil
+	}
+	mediaType, ok := ociMediaTypes[desc.MediaType]
+	if !ok {
+		return fmt.Errorf("cannot convert media type %s of %s to an OCI media type", desc.MediaType, desc.Digest)
+	}
+	desc.MediaType = mediaType
+	return nil
+}
+
+func convertDockerMediaType(desc *ocispec.Descriptor) error {
+	for dockerType, ociType := range ociMediaTypes {
+		if desc.MediaType == dockerType {
+			return nil
+		}
+		if desc.MediaType == ociType {
+			desc.MediaType = dockerType
+			return nil
+		}
+	}
+	return fmt.Errorf("cannot convert media type %s of %s to a Docker v2 media type", desc.MediaType, desc.Digest)
+}
+
+// autoManifestType returns the manifest type for an input with the "auto" type: OCI if
+// it uses features only an OCI index supports, and Docker otherwise
+func autoManifestType(input types.YAMLInput, labels *types.LabelAnnotations) types.ManifestType {
+	if len(input.Annotations) > 0 || labels != nil {
+		return types.OCI
+	}
+	for _, img := range input.Manifests {
+		if len(img.Annotations) > 0 || img.Nested || img.OCI {
+			return types.OCI
+		}
+	}
+	return types.Docker
+}
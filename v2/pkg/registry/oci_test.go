@@ -0,0 +1,83 @@
+package registry
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/estesp/manifest-tool/v2/pkg/store"
+	"github.com/estesp/manifest-tool/v2/pkg/types"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+func TestConvertToOCI(t *testing.T) {
+	cs := store.NewMemoryStore()
+	layer := ocispec.Descriptor{MediaType: types.MediaTypeDockerTarGzipLayer, Digest: digest.FromString("layer"), Size: 5}
+	man := ocispec.Manifest{
+		MediaType: types.MediaTypeDockerSchema2Manifest,
+		Config:    ocispec.Descriptor{MediaType: types.MediaTypeDockerSchema2Config, Digest: digest.FromString("config"), Size: 6},
+		Layers:    []ocispec.Descriptor{layer},
+	}
+	man.SchemaVersion = 2
+	mb, _ := json.Marshal(man)
+	platform := &ocispec.Platform{OS: "linux", Architecture: "amd64"}
+	desc := ocispec.Descriptor{MediaType: types.MediaTypeDockerSchema2Manifest, Digest: digest.FromBytes(mb), Size: int64(len(mb)), Platform: platform}
+	cs.Set(desc, mb)
+
+	converted, err := convertToOCI(cs, desc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if converted.MediaType != ocispec.MediaTypeImageManifest || converted.Digest == desc.Digest || converted.Platform != platform {
+		t.Fatalf("unexpected converted descriptor %+v", converted)
+	}
+	_, cb, ok := cs.Get(converted)
+	if !ok {
+		t.Fatal("converted manifest is not in the content store")
+	}
+	var result ocispec.Manifest
+	if err := json.Unmarshal(cb, &result); err != nil {
+		t.Fatal(err)
+	}
+	if result.MediaType != ocispec.MediaTypeImageManifest || result.Config.MediaType != ocispec.MediaTypeImageConfig || result.Config.Digest != man.Config.Digest {
+		t.Errorf("unexpected converted manifest %+v", result)
+	}
+	if len(result.Layers) != 1 || result.Layers[0].MediaType != ocispec.MediaTypeImageLayerGzip || result.Layers[0].Digest != layer.Digest {
+		t.Errorf("unexpected converted layers %+v", result.Layers)
+	}
+
+	// an OCI manifest is kept as it is
+	if again, err := convertToOCI(cs, converted); err != nil || again.Digest != converted.Digest {
+		t.Errorf("expected OCI manifest %s to be unchanged, got %s (%v)", converted.Digest, again.Digest, err)
+	}
+
+	man.Layers[0].MediaType = "application/vnd.example.layer"
+	mb, _ = json.Marshal(man)
+	desc = ocispec.Descriptor{MediaType: types.MediaTypeDockerSchema2Manifest, Digest: digest.FromBytes(mb), Size: int64(len(mb))}
+	cs.Set(desc, mb)
+	if _, err := convertToOCI(cs, desc); err == nil {
+		t.Error("expected an error for an unknown layer media type")
+	}
+}
+
+func TestAutoManifestType(t *testing.T) {
+	var tests = []struct {
+		name  string
+		input types.YAMLInput
+		want  types.ManifestType
+	}{
+		{name: "plain", input: types.YAMLInput{Manifests: []types.ManifestEntry{{Image: "a"}}}, want: types.Docker},
+		{name: "annotations", input: types.YAMLInput{Annotations: map[string]string{"a": "b"}}, want: types.OCI},
+		{name: "entry annotations", input: types.YAMLInput{Manifests: []types.ManifestEntry{{Image: "a", Annotations: map[string]string{"a": "b"}}}}, want: types.OCI},
+		{name: "nested", input: types.YAMLInput{Manifests: []types.ManifestEntry{{Image: "a", Nested: true}}}, want: types.OCI},
+		{name: "convert", input: types.YAMLInput{Manifests: []types.ManifestEntry{{Image: "a", OCI: true}}}, want: types.OCI},
+	}
+	for _, tt := range tests {
+		if got := autoManifestType(tt.input, nil); got != tt.want {
+			t.Errorf("%s: expected %s, got %s", tt.name, tt.want, got)
+		}
+	}
+	if got := autoManifestType(types.YAMLInput{}, &types.LabelAnnotations{}); got != types.OCI {
+		t.Errorf("labels: expected oci, got %s", got)
+	}
+}
@@ -4,8 +4,11 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"sort"
 	"strings"
 
+	ccontent "github.com/containerd/containerd/v2/core/content"
+	"github.com/containerd/platforms"
 	"github.com/docker/distribution/reference"
 	"github.com/estesp/manifest-tool/v2/pkg/store"
 	"github.com/estesp/manifest-tool/v2/pkg/types"
@@ -14,29 +17,52 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
-func PushManifestList(username, password string, input types.YAMLInput, ignoreMissing, insecure, plainHttp bool, manifestType types.ManifestType, configDir string) (hash string, length int, err error) {
+// labelDistributionSource is the prefix of the containerd content labels recording the
+// repositories content is available from, which enable cross-repo blob mounts on push
+const labelDistributionSource = "containerd.io/distribution.source."
+
+// PushManifestList creates and pushes a manifest list/index from the input with the
+// settings of opts; content is fetched into the content store cs, or into an in-memory
+// store if cs is nil. The types.Auto manifest type is resolved to OCI or Docker for the input.
+func PushManifestList(username, password string, input types.YAMLInput, manifestType types.ManifestType, opts types.PushOptions, configDir string, cs store.ContentStore) (hash string, length int, err error) {
+	if manifestType == types.Auto {
+		manifestType = autoManifestType(input, opts.Labels)
+		logrus.Infof("using manifest type %s for %s", manifestType, input.Image)
+	}
 	// resolve the target image reference for the combined manifest list/index
 	if manifestType == types.Docker && len(input.Annotations) > 0 {
 		return hash, length, fmt.Errorf("manifest list (Docker media type) does not support annotations; use --type oci instead")
 	}
+	for _, img := range input.Manifests {
+		if manifestType == types.Docker && len(img.Annotations) > 0 {
+			return hash, length, fmt.Errorf("manifest list (Docker media type) does not support annotations on manifest entries (%s); use --type oci instead", img.Image)
+		}
+		if manifestType == types.Docker && img.OCI {
+			return hash, length, fmt.Errorf("manifest entry for image %s is converted to OCI, which requires an OCI index; use --type oci instead", img.Image)
+		}
+	}
 	targetRef, err := reference.ParseNormalizedNamed(input.Image)
 	if err != nil {
 		return hash, length, fmt.Errorf("error parsing name for manifest list (%s): %v", input.Image, err)
 	}
 
-	err = util.CreateRegistryHost(targetRef, username, password, insecure, plainHttp, configDir, true)
+	err = util.CreateRegistryHost(targetRef, username, password, opts.Insecure, opts.PlainHTTP, configDir, true)
 	if err != nil {
 		return hash, length, fmt.Errorf("error creating registry host configuration: %v", err)
 	}
 	manifestList := types.ManifestList{
-		Name:        input.Image,
-		Reference:   targetRef,
-		Resolver:    util.GetResolver(),
-		Type:        manifestType,
-		Annotations: input.Annotations,
-	}
-	// create an in-memory store for OCI descriptors and content used during the push operation
-	memoryStore := store.NewMemoryStore()
+		Name:         input.Image,
+		Reference:    targetRef,
+		Resolver:     util.GetResolver(),
+		Type:         manifestType,
+		Annotations:  input.Annotations,
+		Reproducible: opts.Reproducible,
+	}
+	// without a content store provided, use an in-memory store for OCI descriptors and
+	// content used during the push operation
+	if cs == nil {
+		cs = store.NewMemoryStore()
+	}
 
 	// collect descriptors for images and attestations as we walk the included images
 	var (
@@ -54,9 +80,14 @@ func PushManifestList(username, password string, input types.YAMLInput, ignoreMi
 		if reference.Domain(targetRef) != reference.Domain(ref) {
 			return hash, length, fmt.Errorf("source image (%s) registry does not match target image (%s) registry", ref, targetRef)
 		}
-		descriptor, err := FetchDescriptor(util.GetResolver(), memoryStore, ref)
+		selectors, err := parseSelectors(img.Platforms)
+		if err != nil {
+			return hash, length, fmt.Errorf("invalid platforms for image %s: %v", img.Image, err)
+		}
+		img.Platform = util.NormalizePlatform(img.Platform)
+		descriptor, err := FetchDescriptor(util.GetResolver(), cs, ref)
 		if err != nil {
-			if ignoreMissing {
+			if opts.IgnoreMissing {
 				logrus.Warnf("Couldn't access image '%q'. Skipping due to 'ignore missing' configuration.", img.Image)
 				continue
 			}
@@ -66,20 +97,69 @@ func PushManifestList(username, password string, input types.YAMLInput, ignoreMi
 		// Check that only member images of type OCI manifest or Docker v2.2 manifest are included
 		switch descriptor.MediaType {
 		case ocispec.MediaTypeImageIndex, types.MediaTypeDockerSchema2ManifestList:
+			if img.Nested && len(selectors) > 0 {
+				return hash, length, fmt.Errorf("manifest entry for image %s cannot select platforms of a nested index", img.Image)
+			}
+			if img.Nested && img.OCI {
+				return hash, length, fmt.Errorf("manifest entry for image %s cannot convert a nested index to OCI", img.Image)
+			}
+			if img.Nested {
+				// keep the source index as a single entry of the target index
+				if manifestType == types.Docker {
+					return hash, length, fmt.Errorf("manifest list (Docker media type) does not support nested index entries; use --type oci instead")
+				}
+				if img.Platform.OS != "" || img.Platform.Architecture != "" {
+					platform := img.Platform
+					if !util.IsValidOSArch(platform.OS, platform.Architecture, platform.Variant) {
+						return hash, length, fmt.Errorf("manifest entry for image %s has unsupported os/arch or os/arch/variant combination: %s/%s/%s", img.Image, platform.OS, platform.Architecture, platform.Variant)
+					}
+					descriptor.Platform = &platform
+				}
+				manifestDescriptors = append(manifestDescriptors, types.Manifest{
+					Descriptor: withAnnotations(descriptor, img.Annotations),
+					PushRef:    reference.Path(ref) != reference.Path(targetRef),
+				})
+				continue
+			}
 			// check if the index simply has a single image and that other index entries are attestation manifests
-			desc, attestDesc := getImagesFromIndex(descriptor, memoryStore)
+			desc, attestDesc := getImagesFromIndex(descriptor, cs)
+			if len(selectors) > 0 {
+				desc, attestDesc, err = selectPlatforms(desc, attestDesc, selectors)
+				if err != nil {
+					return hash, length, fmt.Errorf("unable to select platforms of image %s: %v", img.Image, err)
+				}
+			}
 			var pushRef bool
 			if reference.Path(ref) != reference.Path(targetRef) {
 				pushRef = true
 			}
+			// converted manifests are new content, which the attestations of the originals do not cover
+			converted := map[string]bool{}
 			for _, d := range desc {
+				d, err = checkIndexPlatform(cs, img.Image, d, opts.PlatformPolicy)
+				if err != nil {
+					return hash, length, err
+				}
 				man := types.Manifest{
-					Descriptor: d,
+					Descriptor: withAnnotations(d, img.Annotations),
 					PushRef:    pushRef,
 				}
+				if img.OCI && d.MediaType == types.MediaTypeDockerSchema2Manifest {
+					man.Descriptor, err = convertToOCI(cs, man.Descriptor)
+					if err != nil {
+						return hash, length, fmt.Errorf("unable to convert manifest %s of image %s to OCI: %v", d.Digest, img.Image, err)
+					}
+					man.PushRef = true
+					converted[d.Digest.String()] = true
+				}
 				manifestDescriptors = append(manifestDescriptors, man)
 			}
 			for _, d := range attestDesc {
+				if ref := d.Annotations["vnd.docker.reference.digest"]; converted[ref] {
+					// the in-toto statements of the attestation name the original manifest as their subject
+					logrus.Warnf("dropping attestation %s of image %s: its manifest %s was converted to OCI, which the attestation does not cover", d.Digest, img.Image, ref)
+					continue
+				}
 				man := types.Manifest{
 					Descriptor: d,
 					PushRef:    pushRef,
@@ -87,19 +167,41 @@ func PushManifestList(username, password string, input types.YAMLInput, ignoreMi
 				attestationDescriptors = append(attestationDescriptors, man)
 			}
 		case ocispec.MediaTypeImageManifest, types.MediaTypeDockerSchema2Manifest:
+			if len(selectors) > 0 {
+				return hash, length, fmt.Errorf("manifest entry for image %s selects platforms but the image is not a manifest list/index", img.Image)
+			}
 			var (
 				man       ocispec.Manifest
 				imgConfig types.Image
 				pushRef   bool
 			)
 			// finalize the platform object that will be used to push with this manifest
-			_, db, _ := memoryStore.Get(descriptor)
+			_, db, _ := cs.Get(descriptor)
 			if err := json.Unmarshal(db, &man); err != nil {
 				return hash, length, fmt.Errorf("could not unmarshal manifest object from descriptor for image '%s': %v", img.Image, err)
 			}
-			_, cb, _ := memoryStore.Get(man.Config)
-			if err := json.Unmarshal(cb, &imgConfig); err != nil {
-				return hash, length, fmt.Errorf("could not unmarshal config object from descriptor for image '%s': %v", img.Image, err)
+			if types.IsArtifactManifest(man) {
+				// artifacts have no image config to take platform details from; the
+				// platform must be provided explicitly in the input
+				if manifestType == types.Docker {
+					return hash, length, fmt.Errorf("manifest list (Docker media type) does not support artifact manifest entries (%s); use --type oci instead", img.Image)
+				}
+				if img.Platform.OS == "" || img.Platform.Architecture == "" {
+					return hash, length, fmt.Errorf("artifact manifest for image %s requires an explicit platform (os and architecture) in the manifest entry", img.Image)
+				}
+				descriptor.ArtifactType = man.ArtifactType
+				if descriptor.ArtifactType == "" {
+					descriptor.ArtifactType = man.Config.MediaType
+				}
+			} else {
+				_, cb, _ := cs.Get(man.Config)
+				if err := json.Unmarshal(cb, &imgConfig); err != nil {
+					return hash, length, fmt.Errorf("could not unmarshal config object from descriptor for image '%s': %v", img.Image, err)
+				}
+				img.Platform, err = checkPlatform(img, imgConfig, opts.PlatformPolicy)
+				if err != nil {
+					return hash, length, err
+				}
 			}
 			descriptor.Platform, err = resolvePlatform(descriptor, img, imgConfig)
 			if err != nil {
@@ -108,75 +210,84 @@ func PushManifestList(username, password string, input types.YAMLInput, ignoreMi
 			if reference.Path(ref) != reference.Path(targetRef) {
 				pushRef = true
 			}
+			if img.OCI && descriptor.MediaType == types.MediaTypeDockerSchema2Manifest {
+				descriptor, err = convertToOCI(cs, descriptor)
+				if err != nil {
+					return hash, length, fmt.Errorf("unable to convert image %s to OCI: %v", img.Image, err)
+				}
+				pushRef = true
+			}
 			manifestDescriptors = append(manifestDescriptors, types.Manifest{
-				Descriptor: descriptor,
+				Descriptor: withAnnotations(descriptor, img.Annotations),
 				PushRef:    pushRef,
 			})
+		case types.MediaTypeDockerSchema1Manifest, types.MediaTypeDockerSchema1UnsignedManifest:
+			if len(selectors) > 0 {
+				return hash, length, fmt.Errorf("manifest entry for image %s selects platforms but the image is not a manifest list/index", img.Image)
+			}
+			if !img.Convert {
+				return hash, length, fmt.Errorf("image %s is a legacy Docker schema 1 manifest which cannot be included in a manifest list/index; use --convert-schema1 to convert it", img.Image)
+			}
+			// the converted manifest is new content, so it is always pushed to the target repository
+			converted, err := convertSchema1(context.Background(), cs, util.GetResolver(), ref, descriptor, manifestType)
+			if err != nil {
+				return hash, length, fmt.Errorf("unable to convert schema 1 image '%s': %v", img.Image, err)
+			}
+			converted.Platform, err = resolvePlatform(converted, img, types.Image{})
+			if err != nil {
+				return hash, length, fmt.Errorf("unable to create platform object for manifest %s: %v", converted.Digest.String(), err)
+			}
+			manifestDescriptors = append(manifestDescriptors, types.Manifest{
+				Descriptor: withAnnotations(converted, img.Annotations),
+				PushRef:    true,
+			})
 		default:
 			return hash, length, fmt.Errorf("cannot include unknown media type '%s' in a manifest list/index push", descriptor.MediaType)
 		}
 	}
 
+	if opts.Labels != nil {
+		if manifestType == types.Docker {
+			return hash, length, fmt.Errorf("manifest list (Docker media type) does not support annotations from image labels; use --type oci instead")
+		}
+		manifestList.Annotations = promoteLabels(cs, manifestDescriptors, opts.Labels.Keys, input.Annotations)
+	}
+
 	platforms = make(map[string]ocispec.Descriptor)
 
 	// add image manifests to final index/manifestlist
 	for _, manifest := range manifestDescriptors {
-		// first make sure we haven't already encountered an image with this platform
-		platStr := getPlatformString(manifest.Descriptor.Platform)
-		if otherDesc, ok := platforms[platStr]; ok {
-			return hash, length, fmt.Errorf("cannot include two manifests with the same platform; digest %s already provides platform %s (this digest: %s)", otherDesc.Digest.String(),
-				platStr, manifest.Descriptor.Digest.String())
-		}
-		platforms[platStr] = manifest.Descriptor
-
-		var man ocispec.Manifest
-		_, db, _ := memoryStore.Get(manifest.Descriptor)
-		if err := json.Unmarshal(db, &man); err != nil {
-			return hash, length, fmt.Errorf("could not unmarshal manifest object from descriptor '%s': %v", manifest.Descriptor.Digest.String(), err)
-		}
-		// set labels for handling distribution source to get automatic cross-repo blob mounting for the layers
-		info, _ := memoryStore.Info(context.TODO(), manifest.Descriptor.Digest)
-		for _, layer := range man.Layers {
-			// only need to handle cross-repo blob mount for distributable layer types
-			if skippable(layer.MediaType) {
-				continue
-			}
-			info.Digest = layer.Digest
-			if _, err := memoryStore.Update(context.TODO(), info, ""); err != nil {
-				logrus.Warnf("couldn't update in-memory store labels for %v: %v", info.Digest, err)
+		// first make sure we haven't already encountered an image with this platform; nested
+		// indexes without an explicit platform are not part of this check
+		if manifest.Descriptor.Platform != nil {
+			platStr := getPlatformString(manifest.Descriptor.Platform)
+			if otherDesc, ok := platforms[platStr]; ok {
+				return hash, length, fmt.Errorf("cannot include two manifests with the same platform; digest %s already provides platform %s (this digest: %s)", otherDesc.Digest.String(),
+					platStr, manifest.Descriptor.Digest.String())
 			}
+			platforms[platStr] = manifest.Descriptor
+		}
+		if err := setLayerLabels(cs, manifest.Descriptor); err != nil {
+			return hash, length, err
 		}
 		manifestList.Manifests = append(manifestList.Manifests, manifest)
 	}
 
 	// add attestations to final index/manifestlist
 	for _, attestation := range attestationDescriptors {
-		_, db, _ := memoryStore.Get(attestation.Descriptor)
-		var man ocispec.Manifest
-		if err := json.Unmarshal(db, &man); err != nil {
-			return hash, length, fmt.Errorf("could not unmarshal attestation object from descriptor '%s': %v", attestation.Descriptor.Digest.String(), err)
-		}
-		info, _ := memoryStore.Info(context.TODO(), attestation.Descriptor.Digest)
-		for _, layer := range man.Layers {
-			// only need to handle cross-repo blob mount for distributable layer types
-			if skippable(layer.MediaType) {
-				continue
-			}
-			info.Digest = layer.Digest
-			if _, err := memoryStore.Update(context.TODO(), info, ""); err != nil {
-				logrus.Warnf("couldn't update in-memory store labels for %v: %v", info.Digest, err)
-			}
+		if err := setLayerLabels(cs, attestation.Descriptor); err != nil {
+			return hash, length, err
 		}
 		manifestList.Manifests = append(manifestList.Manifests, attestation)
 	}
 
-	if ignoreMissing && len(manifestList.Manifests) == 0 {
+	if opts.IgnoreMissing && len(manifestList.Manifests) == 0 {
 		// we need to verify we at least have one valid entry in the list
 		// otherwise our manifest list will be totally empty
 		return hash, length, fmt.Errorf("all entries were skipped due to missing source image references; no manifest list to push")
 	}
 
-	return Push(manifestList, input.Tags, memoryStore)
+	return Push(manifestList, input.Tags, cs)
 }
 
 func resolvePlatform(descriptor ocispec.Descriptor, img types.ManifestEntry, imgConfig types.Image) (*ocispec.Platform, error) {
@@ -205,6 +316,9 @@ func resolvePlatform(descriptor ocispec.Descriptor, img types.ManifestEntry, img
 		platform.OSFeatures = imgConfig.OSFeatures
 	}
 
+	normalized := util.NormalizePlatform(*platform)
+	platform = &normalized
+
 	// validate os/arch input
 	if !util.IsValidOSArch(platform.OS, platform.Architecture, platform.Variant) {
 		return nil, fmt.Errorf("manifest entry for image %s has unsupported os/arch or os/arch/variant combination: %s/%s/%s", img.Image, platform.OS, platform.Architecture, platform.Variant)
@@ -212,6 +326,253 @@ func resolvePlatform(descriptor ocispec.Descriptor, img types.ManifestEntry, img
 	return platform, nil
 }
 
+// checkPlatform compares the platform declared in a manifest entry with the os,
+// architecture and variant of its image config, and handles a mismatch according to
+// the policy; it returns the platform to use for the entry. Variants are only compared
+// when both have one, as image configs often leave it out.
+func checkPlatform(img types.ManifestEntry, imgConfig types.Image, policy types.PlatformPolicy) (ocispec.Platform, error) {
+	declared := img.Platform
+	if (declared.OS == "" && declared.Architecture == "") || (imgConfig.OS == "" && imgConfig.Architecture == "") {
+		return declared, nil
+	}
+	actual := util.NormalizePlatform(ocispec.Platform{OS: imgConfig.OS, Architecture: imgConfig.Architecture, Variant: imgConfig.Variant})
+	if platformsAgree(declared, actual) {
+		return declared, nil
+	}
+	mismatch := fmt.Errorf("manifest entry for image %s declares platform %s but its image config is for %s", img.Image, platforms.Format(declared), platforms.Format(actual))
+	switch policy {
+	case types.PlatformError:
+		return declared, mismatch
+	case types.PlatformOverride:
+		logrus.Warnf("%v; using the platform of the image config", mismatch)
+		declared.OS, declared.Architecture, declared.Variant = actual.OS, actual.Architecture, actual.Variant
+		return declared, nil
+	default:
+		logrus.Warn(mismatch)
+		return declared, nil
+	}
+}
+
+// checkIndexPlatform normalizes the platform of a manifest taken from the index of an
+// image and, as for a manifest entry with a platform, checks it against the image config
+// of the manifest according to the policy. A manifest without a platform in the index
+// takes the platform of its image config.
+func checkIndexPlatform(cs store.ContentStore, image string, desc ocispec.Descriptor, policy types.PlatformPolicy) (ocispec.Descriptor, error) {
+	var entry types.ManifestEntry
+	entry.Image = image
+	if desc.Platform != nil {
+		entry.Platform = util.NormalizePlatform(*desc.Platform)
+		desc.Platform = &entry.Platform
+	}
+	if desc.MediaType != ocispec.MediaTypeImageManifest && desc.MediaType != types.MediaTypeDockerSchema2Manifest {
+		return desc, nil
+	}
+	_, db, found := cs.Get(desc)
+	if !found {
+		return desc, nil
+	}
+	var man ocispec.Manifest
+	if err := json.Unmarshal(db, &man); err != nil {
+		return desc, fmt.Errorf("could not unmarshal manifest %s of image %s: %v", desc.Digest, image, err)
+	}
+	if types.IsArtifactManifest(man) {
+		return desc, nil
+	}
+	_, cb, found := cs.Get(man.Config)
+	if !found {
+		return desc, nil
+	}
+	var imgConfig types.Image
+	if err := json.Unmarshal(cb, &imgConfig); err != nil {
+		return desc, fmt.Errorf("could not unmarshal config of manifest %s of image %s: %v", desc.Digest, image, err)
+	}
+	if desc.Platform == nil {
+		platform, err := resolvePlatform(desc, entry, imgConfig)
+		if err != nil {
+			return desc, err
+		}
+		desc.Platform = platform
+		return desc, nil
+	}
+	platform, err := checkPlatform(entry, imgConfig, policy)
+	if err != nil {
+		return desc, fmt.Errorf("%w (manifest %s)", err, desc.Digest)
+	}
+	desc.Platform = &platform
+	return desc, nil
+}
+
+// platformsAgree returns true if the os, architecture and variant of the two platforms
+// which are set in both are equivalent under the containerd platform rules
+func platformsAgree(a, b ocispec.Platform) bool {
+	if a.OS != "" && b.OS != "" && a.OS != b.OS {
+		return false
+	}
+	if a.Architecture != "" && b.Architecture != "" {
+		na, nb := platforms.Normalize(a), platforms.Normalize(b)
+		if na.Architecture != nb.Architecture {
+			return false
+		}
+		if a.Variant != "" && b.Variant != "" && na.Variant != nb.Variant {
+			return false
+		}
+	}
+	return true
+}
+
+// promoteLabels returns the index annotations with the config labels of the image
+// manifests added, for the labels of keys (or all labels if keys is empty) which have
+// the same value in every image; annotations which are already set are kept.
+// Manifests without an image config, such as nested indexes and artifacts, are ignored.
+func promoteLabels(cs store.ContentStore, manifests []types.Manifest, keys []string, annotations map[string]string) map[string]string {
+	var imageLabels []map[string]string
+	for _, m := range manifests {
+		switch m.Descriptor.MediaType {
+		case ocispec.MediaTypeImageManifest, types.MediaTypeDockerSchema2Manifest:
+		default:
+			continue
+		}
+		var (
+			man       ocispec.Manifest
+			imgConfig types.Image
+		)
+		_, db, ok := cs.Get(m.Descriptor)
+		if !ok || json.Unmarshal(db, &man) != nil || types.IsArtifactManifest(man) {
+			continue
+		}
+		_, cb, ok := cs.Get(man.Config)
+		if !ok || json.Unmarshal(cb, &imgConfig) != nil {
+			logrus.Warnf("unable to read the image config of manifest %s; its labels are not considered for index annotations", m.Descriptor.Digest)
+			continue
+		}
+		imageLabels = append(imageLabels, imgConfig.Config.Labels)
+	}
+	if len(imageLabels) == 0 {
+		return annotations
+	}
+
+	explicit := len(keys) > 0
+	if !explicit {
+		seen := map[string]bool{}
+		for _, l := range imageLabels {
+			for k := range l {
+				if !seen[k] {
+					seen[k] = true
+					keys = append(keys, k)
+				}
+			}
+		}
+		sort.Strings(keys)
+	}
+	merged := make(map[string]string, len(annotations)+len(keys))
+	for k, v := range annotations {
+		merged[k] = v
+	}
+	for _, key := range keys {
+		if _, ok := annotations[key]; ok {
+			continue
+		}
+		var (
+			values  []string
+			missing bool
+		)
+		for _, l := range imageLabels {
+			if v, ok := l[key]; ok {
+				values = append(values, v)
+			} else {
+				missing = true
+			}
+		}
+		differs := false
+		for _, v := range values {
+			if v != values[0] {
+				logrus.Warnf("label %s differs between member images (%q and %q); not added to the index annotations", key, values[0], v)
+				differs = true
+				break
+			}
+		}
+		switch {
+		case differs:
+		case missing:
+			if explicit {
+				logrus.Warnf("label %s is missing from member images; not added to the index annotations", key)
+			}
+		default:
+			merged[key] = values[0]
+		}
+	}
+	return merged
+}
+
+// withAnnotations returns the descriptor with the annotations of its manifest entry
+// added to (or replacing) any annotations it already has
+func withAnnotations(desc ocispec.Descriptor, annotations map[string]string) ocispec.Descriptor {
+	if len(annotations) == 0 {
+		return desc
+	}
+	merged := make(map[string]string, len(desc.Annotations)+len(annotations))
+	for k, v := range desc.Annotations {
+		merged[k] = v
+	}
+	for k, v := range annotations {
+		merged[k] = v
+	}
+	desc.Annotations = merged
+	return desc
+}
+
+// setLayerLabels copies the distribution source labels of a manifest to each of its
+// layers to get automatic cross-repo blob mounting for the layers during push. For
+// an index, the labels are set for the layers of every manifest it references.
+func setLayerLabels(ms store.ContentStore, desc ocispec.Descriptor) error {
+	_, db, _ := ms.Get(desc)
+	switch desc.MediaType {
+	case ocispec.MediaTypeImageIndex, types.MediaTypeDockerSchema2ManifestList:
+		var index ocispec.Index
+		if err := json.Unmarshal(db, &index); err != nil {
+			return fmt.Errorf("could not unmarshal index object from descriptor '%s': %v", desc.Digest.String(), err)
+		}
+		for _, child := range index.Manifests {
+			if err := setLayerLabels(ms, child); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	var man ocispec.Manifest
+	if err := json.Unmarshal(db, &man); err != nil {
+		return fmt.Errorf("could not unmarshal manifest object from descriptor '%s': %v", desc.Digest.String(), err)
+	}
+	info, _ := ms.Info(context.TODO(), desc.Digest)
+	// only the distribution source labels are updated, leaving any other labels a
+	// (persistent) content store keeps for the layers intact
+	labels := map[string]string{}
+	var fieldpaths []string
+	for k, v := range info.Labels {
+		if strings.HasPrefix(k, labelDistributionSource) {
+			labels[k] = v
+			fieldpaths = append(fieldpaths, "labels."+k)
+		}
+	}
+	if len(fieldpaths) == 0 {
+		return nil
+	}
+	for _, layer := range man.Layers {
+		// only need to handle cross-repo blob mount for distributable layer types
+		if skippable(layer.MediaType) {
+			continue
+		}
+		layerInfo := ccontent.Info{
+			Digest: layer.Digest,
+			Labels: labels,
+		}
+		if _, err := ms.Update(context.TODO(), layerInfo, fieldpaths...); err != nil {
+			logrus.Warnf("couldn't update content store labels for %v: %v", layer.Digest, err)
+		}
+	}
+	return nil
+}
+
 func skippable(mediaType string) bool {
 	// skip foreign/non-distributable layers
 	if strings.Index(mediaType, "foreign") > 0 || strings.Index(mediaType, "nondistributable") > 0 {
@@ -234,7 +595,7 @@ func isAttestationManifest(desc ocispec.Descriptor) bool {
 	return false
 }
 
-func getImagesFromIndex(desc ocispec.Descriptor, ms *store.MemoryStore) ([]ocispec.Descriptor, []ocispec.Descriptor) {
+func getImagesFromIndex(desc ocispec.Descriptor, ms store.ContentStore) ([]ocispec.Descriptor, []ocispec.Descriptor) {
 	var (
 		manifests    []ocispec.Descriptor
 		attestations []ocispec.Descriptor
@@ -255,6 +616,60 @@ func getImagesFromIndex(desc ocispec.Descriptor, ms *store.MemoryStore) ([]ocisp
 	return manifests, attestations
 }
 
+// parseSelectors parses the os/arch[/variant] platforms selected from an index,
+// normalizing aliases such as aarch64 to their OCI names
+func parseSelectors(selectors []string) ([]ocispec.Platform, error) {
+	var parsed []ocispec.Platform
+	for _, selector := range selectors {
+		p, err := types.ParsePlatform(selector)
+		if err != nil {
+			return nil, err
+		}
+		parsed = append(parsed, util.NormalizePlatform(p))
+	}
+	return parsed, nil
+}
+
+// selectPlatforms returns the manifests of an index which match any of the platform
+// selectors, along with the attestation manifests which refer to those manifests.
+// Every selector must match at least one manifest.
+func selectPlatforms(manifests, attestations []ocispec.Descriptor, selectors []ocispec.Platform) ([]ocispec.Descriptor, []ocispec.Descriptor, error) {
+	var (
+		selected         []ocispec.Descriptor
+		selectedAttests  []ocispec.Descriptor
+		selectedDigests  = map[string]bool{}
+		matchedSelectors = make([]bool, len(selectors))
+	)
+	for _, m := range manifests {
+		if m.Platform == nil {
+			continue
+		}
+		matched := false
+		platform := util.NormalizePlatform(*m.Platform)
+		for i, selector := range selectors {
+			if types.PlatformMatches(selector, platform) {
+				matchedSelectors[i] = true
+				matched = true
+			}
+		}
+		if matched {
+			selected = append(selected, m)
+			selectedDigests[m.Digest.String()] = true
+		}
+	}
+	for i, matched := range matchedSelectors {
+		if !matched {
+			return nil, nil, fmt.Errorf("no manifest for platform %s", platforms.Format(selectors[i]))
+		}
+	}
+	for _, a := range attestations {
+		if selectedDigests[a.Annotations["vnd.docker.reference.digest"]] {
+			selectedAttests = append(selectedAttests, a)
+		}
+	}
+	return selected, selectedAttests, nil
+}
+
 func getPlatformString(platform *ocispec.Platform) string {
 	return fmt.Sprintf("%s-%s-%s-%s-%s",
 		platform.Architecture,
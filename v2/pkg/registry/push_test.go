@@ -0,0 +1,245 @@
+package registry
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/containerd/platforms"
+	"github.com/estesp/manifest-tool/v2/pkg/store"
+	"github.com/estesp/manifest-tool/v2/pkg/types"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+func TestSelectPlatforms(t *testing.T) {
+	manifest := func(platform string) ocispec.Descriptor {
+		p := platforms.MustParse(platform)
+		return ocispec.Descriptor{
+			MediaType: ocispec.MediaTypeImageManifest,
+			Digest:    digest.FromString(platform),
+			Platform:  &p,
+		}
+	}
+	attestation := func(m ocispec.Descriptor) ocispec.Descriptor {
+		return ocispec.Descriptor{
+			MediaType: ocispec.MediaTypeImageManifest,
+			Digest:    digest.FromString("attestation " + m.Digest.String()),
+			Annotations: map[string]string{
+				"vnd.docker.reference.type":   "attestation-manifest",
+				"vnd.docker.reference.digest": m.Digest.String(),
+			},
+		}
+	}
+	manifests := []ocispec.Descriptor{manifest("linux/amd64"), manifest("linux/arm64"), manifest("linux/arm/v7"), manifest("linux/arm/v6")}
+	var attestations []ocispec.Descriptor
+	for _, m := range manifests {
+		attestations = append(attestations, attestation(m))
+	}
+
+	var tests = []struct {
+		selectors []string
+		want      []int
+		err       string
+	}{
+		{selectors: []string{"linux/arm64"}, want: []int{1}},
+		{selectors: []string{"linux/amd64", "linux/arm/v7"}, want: []int{0, 2}},
+		{selectors: []string{"linux/arm"}, want: []int{2, 3}},
+		{selectors: []string{"linux/aarch64"}, want: []int{1}},
+		{selectors: []string{"linux/arm64/v8", "linux/armhf"}, want: []int{1, 2}},
+		{selectors: []string{"linux/x86_64", "linux/riscv64"}, err: "no manifest for platform linux/riscv64"},
+		{selectors: []string{"linux/amd64", "linux/s390x"}, err: "no manifest for platform linux/s390x"},
+	}
+	for _, tt := range tests {
+		selectors, err := parseSelectors(tt.selectors)
+		if err != nil {
+			t.Fatal(err)
+		}
+		selected, selectedAttests, err := selectPlatforms(manifests, attestations, selectors)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%v: expected error %q, got %v", tt.selectors, tt.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: unexpected error %v", tt.selectors, err)
+			continue
+		}
+		if len(selected) != len(tt.want) || len(selectedAttests) != len(tt.want) {
+			t.Errorf("%v: expected %d manifests and attestations, got %d and %d", tt.selectors, len(tt.want), len(selected), len(selectedAttests))
+			continue
+		}
+		for i, idx := range tt.want {
+			if selected[i].Digest != manifests[idx].Digest || selectedAttests[i].Digest != attestations[idx].Digest {
+				t.Errorf("%v: unexpected selection %v / %v", tt.selectors, selected[i], selectedAttests[i])
+			}
+		}
+	}
+}
+
+func TestCheckPlatform(t *testing.T) {
+	config := types.Image{}
+	config.OS = "linux"
+	config.Architecture = "amd64"
+
+	var tests = []struct {
+		declared string
+		policy   types.PlatformPolicy
+		want     string
+		err      bool
+	}{
+		{declared: "linux/amd64", policy: types.PlatformError, want: "linux/amd64"},
+		{declared: "linux/arm64", policy: types.PlatformWarn, want: "linux/arm64"},
+		{declared: "linux/arm64", policy: types.PlatformError, err: true},
+		{declared: "linux/arm64/v8", policy: types.PlatformOverride, want: "linux/amd64"},
+		{declared: "windows/amd64", policy: types.PlatformError, err: true},
+	}
+	for _, tt := range tests {
+		declared, err := types.ParsePlatform(tt.declared)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := checkPlatform(types.ManifestEntry{Image: "myreg/app:amd64", Platform: declared}, config, tt.policy)
+		if tt.err {
+			if err == nil || !strings.Contains(err.Error(), "but its image config is for linux/amd64") {
+				t.Errorf("%s: expected a mismatch error, got %v", tt.declared, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tt.declared, err)
+		} else if platforms.Format(got) != tt.want {
+			t.Errorf("%s: expected platform %s, got %s", tt.declared, tt.want, platforms.Format(got))
+		}
+	}
+
+	config.Architecture = "arm64"
+	config.Variant = "v8"
+	for _, declared := range []ocispec.Platform{{OS: "linux", Architecture: "arm64"}, {OS: "linux", Architecture: "aarch64"}} {
+		if _, err := checkPlatform(types.ManifestEntry{Image: "myreg/app:arm64", Platform: declared}, config, types.PlatformError); err != nil {
+			t.Errorf("%s: unexpected error %v", platforms.Format(declared), err)
+		}
+	}
+}
+
+func TestCheckIndexPlatform(t *testing.T) {
+	cs := store.NewMemoryStore()
+	image := func(os, arch, variant string) ocispec.Descriptor {
+		var config types.Image
+		config.OS, config.Architecture, config.Variant = os, arch, variant
+		cb, _ := json.Marshal(config)
+		configDesc := ocispec.Descriptor{MediaType: ocispec.MediaTypeImageConfig, Digest: digest.FromBytes(cb), Size: int64(len(cb))}
+		if err := cs.Set(configDesc, cb); err != nil {
+			t.Fatal(err)
+		}
+		mb, _ := json.Marshal(ocispec.Manifest{MediaType: ocispec.MediaTypeImageManifest, Config: configDesc})
+		desc := ocispec.Descriptor{MediaType: ocispec.MediaTypeImageManifest, Digest: digest.FromBytes(mb), Size: int64(len(mb))}
+		if err := cs.Set(desc, mb); err != nil {
+			t.Fatal(err)
+		}
+		return desc
+	}
+	withPlatform := func(desc ocispec.Descriptor, platform string) ocispec.Descriptor {
+		p, err := types.ParsePlatform(platform)
+		if err != nil {
+			t.Fatal(err)
+		}
+		desc.Platform = &p
+		return desc
+	}
+	arm64 := image("linux", "arm64", "v8")
+
+	var tests = []struct {
+		desc   ocispec.Descriptor
+		policy types.PlatformPolicy
+		want   string
+		err    bool
+	}{
+		// platforms are normalized
+		{desc: withPlatform(arm64, "linux/aarch64"), policy: types.PlatformError, want: "linux/arm64"},
+		{desc: withPlatform(arm64, "linux/amd64"), policy: types.PlatformError, err: true},
+		{desc: withPlatform(arm64, "linux/amd64"), policy: types.PlatformWarn, want: "linux/amd64"},
+		{desc: withPlatform(arm64, "linux/amd64"), policy: types.PlatformOverride, want: "linux/arm64/v8"},
+		// an entry without a platform takes the one of its image config
+		{desc: arm64, policy: types.PlatformError, want: "linux/arm64/v8"},
+		{desc: withPlatform(ocispec.Descriptor{MediaType: ocispec.MediaTypeImageIndex, Digest: digest.FromString("nested")}, "linux/x86_64"), policy: types.PlatformError, want: "linux/amd64"},
+	}
+	for _, tt := range tests {
+		got, err := checkIndexPlatform(cs, "myreg/app:1", tt.desc, tt.policy)
+		if tt.err {
+			if err == nil || !strings.Contains(err.Error(), "but its image config is for linux/arm64/v8") {
+				t.Errorf("expected a mismatch error, got %v", err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("unexpected error %v", err)
+		} else if got.Platform == nil || platforms.Format(*got.Platform) != tt.want {
+			t.Errorf("expected platform %s, got %v", tt.want, got.Platform)
+		}
+	}
+}
+
+func TestPromoteLabels(t *testing.T) {
+	cs := store.NewMemoryStore()
+	image := func(labels map[string]string) types.Manifest {
+		var config types.Image
+		config.OS, config.Architecture = "linux", "amd64"
+		config.Config.Labels = labels
+		cb, _ := json.Marshal(config)
+		configDesc := ocispec.Descriptor{MediaType: ocispec.MediaTypeImageConfig, Digest: digest.FromBytes(cb), Size: int64(len(cb))}
+		cs.Set(configDesc, cb)
+		mb, _ := json.Marshal(ocispec.Manifest{MediaType: ocispec.MediaTypeImageManifest, Config: configDesc})
+		desc := ocispec.Descriptor{MediaType: ocispec.MediaTypeImageManifest, Digest: digest.FromBytes(mb), Size: int64(len(mb))}
+		cs.Set(desc, mb)
+		return types.Manifest{Descriptor: desc}
+	}
+	manifests := []types.Manifest{
+		image(map[string]string{"org.opencontainers.image.source": "https://github.com/org/app", "org.opencontainers.image.version": "1.0", "arch": "amd64", "only.one": "x"}),
+		image(map[string]string{"org.opencontainers.image.source": "https://github.com/org/app", "org.opencontainers.image.version": "1.0", "arch": "arm64"}),
+		{Descriptor: ocispec.Descriptor{MediaType: ocispec.MediaTypeImageIndex, Digest: digest.FromString("nested")}},
+	}
+
+	got := promoteLabels(cs, manifests, nil, map[string]string{"org.opencontainers.image.version": "1.0.1"})
+	want := map[string]string{"org.opencontainers.image.source": "https://github.com/org/app", "org.opencontainers.image.version": "1.0.1"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected annotations %v, got %v", want, got)
+	}
+	got = promoteLabels(cs, manifests, []string{"org.opencontainers.image.version", "arch", "missing"}, nil)
+	want = map[string]string{"org.opencontainers.image.version": "1.0"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected annotations %v, got %v", want, got)
+	}
+}
+
+func TestWithAnnotations(t *testing.T) {
+	var tests = []struct {
+		existing    map[string]string
+		annotations map[string]string
+		want        map[string]string
+	}{
+		{existing: nil, annotations: nil, want: nil},
+		{existing: map[string]string{"a": "1"}, annotations: nil, want: map[string]string{"a": "1"}},
+		{existing: nil, annotations: map[string]string{"b": "2"}, want: map[string]string{"b": "2"}},
+		{existing: map[string]string{"a": "1", "b": "old"}, annotations: map[string]string{"b": "2", "c": "3"}, want: map[string]string{"a": "1", "b": "2", "c": "3"}},
+	}
+	for _, tt := range tests {
+		desc := ocispec.Descriptor{MediaType: ocispec.MediaTypeImageManifest, Digest: digest.FromString("image"), Annotations: tt.existing}
+		var before map[string]string
+		if tt.existing != nil {
+			before = make(map[string]string)
+			for k, v := range tt.existing {
+				before[k] = v
+			}
+		}
+		got := withAnnotations(desc, tt.annotations)
+		if !reflect.DeepEqual(got.Annotations, tt.want) {
+			t.Errorf("%v + %v: expected annotations %v, got %v", tt.existing, tt.annotations, tt.want, got.Annotations)
+		}
+		if !reflect.DeepEqual(desc.Annotations, before) {
+			t.Errorf("%v + %v: the annotations of the original descriptor were modified", tt.existing, tt.annotations)
+		}
+	}
+}
@@ -1,10 +1,13 @@
 package registry
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/estesp/manifest-tool/v2/pkg/store"
 	"github.com/estesp/manifest-tool/v2/pkg/types"
@@ -20,42 +23,23 @@ import (
 )
 
 // Push performs the actions required to push content to the specified registry endpoint
-func Push(m types.ManifestList, addedTags []string, ms *store.MemoryStore) (string, int, error) {
+func Push(m types.ManifestList, addedTags []string, ms store.ContentStore) (string, int, error) {
 	// push manifest references to target ref (if required)
 	baseRef := reference.TrimNamed(m.Reference)
-	for _, man := range m.Manifests {
-		if man.PushRef {
-			ref, err := reference.WithDigest(baseRef, man.Descriptor.Digest)
-			if err != nil {
-				return "", 0, fmt.Errorf("error parsing reference for target manifest component push: %s: %w", m.Reference.String(), err)
-			}
-			err = push(ref, man.Descriptor, m.Resolver, ms)
-			if err != nil {
-				return "", 0, fmt.Errorf("error pushing target manifest component reference: %s: %w", ref.String(), err)
-			}
-			logrus.Infof("pushed manifest component reference (%s) to target namespace: %s", man.Descriptor.Digest.String(), ref.String())
-		}
+	if err := pushManifestRefs(m.Reference, m.Manifests, m.Resolver, ms); err != nil {
+		return "", 0, err
 	}
 	// build the manifest list/index entry to be pushed and save it in the content store
 	desc, indexJSON, err := buildManifest(m)
 	if err != nil {
 		return "", 0, fmt.Errorf("error creating manifest list/index JSON: %w", err)
 	}
-	ms.Set(desc, indexJSON)
+	if err := ms.Set(desc, indexJSON); err != nil {
+		return "", 0, fmt.Errorf("error storing manifest list/index JSON: %w", err)
+	}
 
-	if err := push(m.Reference, desc, m.Resolver, ms); err != nil {
-		if strings.Contains(fmt.Sprint(err), "cannot reuse body") {
-			// until containerd/containerd issue #5978 (https://github.com/containerd/containerd/issues/5978) is
-			// fixed, we can work around this by attempting the push again now that the auth 401 is handled for
-			// registries like GCR and Quay.io
-			logrus.Debugf("body reuse error; will retry: %+v", err)
-			err := push(m.Reference, desc, m.Resolver, ms)
-			if err != nil {
-				return "", 0, fmt.Errorf("error pushing manifest list/index to registry: %s: %w", desc.Digest.String(), err)
-			}
-		} else {
-			return "", 0, fmt.Errorf("error pushing manifest list/index to registry: %s: %w", desc.Digest.String(), err)
-		}
+	if err := pushIndex(m.Reference, desc, m.Resolver, ms); err != nil {
+		return "", 0, err
 	}
 	for _, tag := range addedTags {
 		taggedRef, err := reference.WithTag(baseRef, tag)
@@ -75,6 +59,18 @@ func buildManifest(m types.ManifestList) (ocispec.Descriptor, []byte, error) {
 		index     interface{}
 		mediaType string
 	)
+	if m.Reproducible != nil {
+		m.Manifests = sortManifests(m.Manifests, m.Reproducible.PlatformOrder)
+		if !m.Reproducible.Created.IsZero() && m.Type == types.OCI {
+			if _, ok := m.Annotations[ocispec.AnnotationCreated]; !ok {
+				annotations := map[string]string{ocispec.AnnotationCreated: m.Reproducible.Created.UTC().Format(time.RFC3339)}
+				for k, v := range m.Annotations {
+					annotations[k] = v
+				}
+				m.Annotations = annotations
+			}
+		}
+	}
 	switch m.Type {
 	case types.Docker:
 		index = dockerManifestList(m.Manifests)
@@ -85,7 +81,15 @@ func buildManifest(m types.ManifestList) (ocispec.Descriptor, []byte, error) {
 		mediaType = ocispec.MediaTypeImageIndex
 	}
 
-	bytes, err := json.MarshalIndent(index, "", "  ")
+	var (
+		bytes []byte
+		err   error
+	)
+	if m.Reproducible != nil {
+		bytes, err = canonicalJSON(index)
+	} else {
+		bytes, err = json.MarshalIndent(index, "", "  ")
+	}
 	if err != nil {
 		return ocispec.Descriptor{}, []byte{}, err
 	}
@@ -99,7 +103,101 @@ func buildManifest(m types.ManifestList) (ocispec.Descriptor, []byte, error) {
 	return desc, bytes, nil
 }
 
-func push(ref reference.Reference, desc ocispec.Descriptor, resolver remotes.Resolver, ms *store.MemoryStore) error {
+// canonicalJSON encodes v as canonical JSON: the keys of every object (including those
+// of structs) sorted, no insignificant whitespace and no escaping of HTML characters
+func canonicalJSON(v interface{}) ([]byte, error) {
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	// decoding into generic values turns structs into maps, which are encoded with
+	// sorted keys; numbers are kept as they are
+	dec := json.NewDecoder(bytes.NewReader(encoded))
+	dec.UseNumber()
+	var generic interface{}
+	if err := dec.Decode(&generic); err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(generic); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(b.Bytes(), []byte("\n")), nil
+}
+
+// sortManifests orders the manifests of a reproducible manifest list/index: manifests
+// with a platform first, those of the platform order in that order and then the others
+// by os, architecture, variant, os version and features; then entries without a platform
+// (nested indexes), and attestation manifests last, after each other in the order of the
+// manifests they reference. Ties are broken by digest.
+func sortManifests(manifests []types.Manifest, order []ocispec.Platform) []types.Manifest {
+	sorted := make([]types.Manifest, len(manifests))
+	copy(sorted, manifests)
+	orderOf := func(p *ocispec.Platform) int {
+		for i, o := range order {
+			if types.PlatformMatches(o, *p) {
+				return i
+			}
+		}
+		return len(order)
+	}
+	platformKey := func(p *ocispec.Platform) string {
+		return strings.Join([]string{p.OS, p.Architecture, p.Variant, p.OSVersion, strings.Join(p.OSFeatures, ",")}, "/")
+	}
+	group := func(desc ocispec.Descriptor) int {
+		switch {
+		case isAttestationManifest(desc):
+			return 2
+		case desc.Platform == nil:
+			return 1
+		}
+		return 0
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i].Descriptor, sorted[j].Descriptor
+		if ga, gb := group(a), group(b); ga != gb {
+			return ga < gb
+		}
+		if group(a) == 0 {
+			if oa, ob := orderOf(a.Platform), orderOf(b.Platform); oa != ob {
+				return oa < ob
+			}
+			if ka, kb := platformKey(a.Platform), platformKey(b.Platform); ka != kb {
+				return ka < kb
+			}
+		}
+		return a.Digest < b.Digest
+	})
+	// attestations follow the order of the manifests they reference
+	position := map[string]int{}
+	for i, man := range sorted {
+		position[man.Descriptor.Digest.String()] = i
+	}
+	attestations := sorted[len(sorted)-countAttestations(sorted):]
+	sort.SliceStable(attestations, func(i, j int) bool {
+		pa, okA := position[attestations[i].Descriptor.Annotations["vnd.docker.reference.digest"]]
+		pb, okB := position[attestations[j].Descriptor.Annotations["vnd.docker.reference.digest"]]
+		if okA != okB {
+			return okA
+		}
+		return pa < pb
+	})
+	return sorted
+}
+
+func countAttestations(manifests []types.Manifest) int {
+	n := 0
+	for _, man := range manifests {
+		if isAttestationManifest(man.Descriptor) {
+			n++
+		}
+	}
+	return n
+}
+
+func push(ref reference.Reference, desc ocispec.Descriptor, resolver remotes.Resolver, ms store.ContentStore) error {
 	ctx := context.Background()
 	pusher, err := resolver.Pusher(ctx, ref.String())
 	if err != nil {
@@ -123,8 +221,82 @@ func push(ref reference.Reference, desc ocispec.Descriptor, resolver remotes.Res
 	return remotes.PushContent(ctx, pusher, desc, ms, nil, nil, wrapper)
 }
 
+// pushIndexChildren pushes references to each manifest referenced by an index (recursively
+// for nested indexes) to the target namespace; it does nothing for non-index descriptors
+func pushIndexChildren(baseRef reference.Named, desc ocispec.Descriptor, resolver remotes.Resolver, ms store.ContentStore) error {
+	switch desc.MediaType {
+	case ocispec.MediaTypeImageIndex, types.MediaTypeDockerSchema2ManifestList:
+	default:
+		return nil
+	}
+	_, db, _ := ms.Get(desc)
+	var index ocispec.Index
+	if err := json.Unmarshal(db, &index); err != nil {
+		return fmt.Errorf("could not unmarshal nested index %s: %w", desc.Digest.String(), err)
+	}
+	for _, child := range index.Manifests {
+		if err := pushIndexChildren(baseRef, child, resolver, ms); err != nil {
+			return err
+		}
+		ref, err := reference.WithDigest(baseRef, child.Digest)
+		if err != nil {
+			return fmt.Errorf("error parsing reference for nested index component push: %s: %w", child.Digest.String(), err)
+		}
+		if err := push(ref, child, resolver, ms); err != nil {
+			return fmt.Errorf("error pushing nested index component reference: %s: %w", ref.String(), err)
+		}
+		logrus.Infof("pushed nested index component reference (%s) to target namespace: %s", child.Digest.String(), ref.String())
+	}
+	return nil
+}
+
+// pushManifestRefs pushes the manifests of a manifest list/index which are marked with
+// PushRef to the repository of the target reference by their digest
+func pushManifestRefs(target reference.Named, manifests []types.Manifest, resolver remotes.Resolver, ms store.ContentStore) error {
+	baseRef := reference.TrimNamed(target)
+	for _, man := range manifests {
+		if !man.PushRef {
+			continue
+		}
+		ref, err := reference.WithDigest(baseRef, man.Descriptor.Digest)
+		if err != nil {
+			return fmt.Errorf("error parsing reference for target manifest component push: %s: %w", target.String(), err)
+		}
+		// a nested index requires all of its manifests in the target namespace first
+		if err := pushIndexChildren(baseRef, man.Descriptor, resolver, ms); err != nil {
+			return err
+		}
+		err = push(ref, man.Descriptor, resolver, ms)
+		if err != nil {
+			return fmt.Errorf("error pushing target manifest component reference: %s: %w", ref.String(), err)
+		}
+		logrus.Infof("pushed manifest component reference (%s) to target namespace: %s", man.Descriptor.Digest.String(), ref.String())
+	}
+	return nil
+}
+
+// pushIndex pushes a manifest list/index, whose manifests are already in the target
+// repository, to the target reference
+func pushIndex(ref reference.Named, desc ocispec.Descriptor, resolver remotes.Resolver, ms store.ContentStore) error {
+	if err := push(ref, desc, resolver, ms); err != nil {
+		if strings.Contains(fmt.Sprint(err), "cannot reuse body") {
+			// until containerd/containerd issue #5978 (https://github.com/containerd/containerd/issues/5978) is
+			// fixed, we can work around this by attempting the push again now that the auth 401 is handled for
+			// registries like GCR and Quay.io
+			logrus.Debugf("body reuse error; will retry: %+v", err)
+			err := push(ref, desc, resolver, ms)
+			if err != nil {
+				return fmt.Errorf("error pushing manifest list/index to registry: %s: %w", desc.Digest.String(), err)
+			}
+		} else {
+			return fmt.Errorf("error pushing manifest list/index to registry: %s: %w", desc.Digest.String(), err)
+		}
+	}
+	return nil
+}
+
 // used to push only a tag for the "additional tags" feature of manifest-tool
-func pushTagOnly(ref reference.Reference, desc ocispec.Descriptor, resolver remotes.Resolver, ms *store.MemoryStore) error {
+func pushTagOnly(ref reference.Reference, desc ocispec.Descriptor, resolver remotes.Resolver, ms store.ContentStore) error {
 	ctx := context.Background()
 	pusher, err := resolver.Pusher(ctx, ref.String())
 	if err != nil {
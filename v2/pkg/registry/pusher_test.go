@@ -0,0 +1,81 @@
+package registry
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/containerd/platforms"
+	"github.com/estesp/manifest-tool/v2/pkg/types"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+func TestReproducibleManifest(t *testing.T) {
+	manifest := func(platform string) types.Manifest {
+		p := platforms.MustParse(platform)
+		return types.Manifest{Descriptor: ocispec.Descriptor{
+			MediaType: ocispec.MediaTypeImageManifest,
+			Digest:    digest.FromString(platform),
+			Size:      1234,
+			Platform:  &p,
+		}}
+	}
+	attestation := func(m types.Manifest) types.Manifest {
+		p := ocispec.Platform{OS: "unknown", Architecture: "unknown"}
+		return types.Manifest{Descriptor: ocispec.Descriptor{
+			MediaType: ocispec.MediaTypeImageManifest,
+			Digest:    digest.FromString("attestation " + m.Descriptor.Digest.String()),
+			Size:      567,
+			Platform:  &p,
+			Annotations: map[string]string{
+				"vnd.docker.reference.type":   "attestation-manifest",
+				"vnd.docker.reference.digest": m.Descriptor.Digest.String(),
+			},
+		}}
+	}
+	amd64, arm64, s390x := manifest("linux/amd64"), manifest("linux/arm64"), manifest("linux/s390x")
+	build := func(manifests []types.Manifest, order []ocispec.Platform) (ocispec.Descriptor, string) {
+		desc, b, err := buildManifest(types.ManifestList{
+			Name:         "myreg/app:1",
+			Type:         types.OCI,
+			Manifests:    manifests,
+			Annotations:  map[string]string{"b": "<2>", "a": "1"},
+			Reproducible: &types.Reproducible{PlatformOrder: order, Created: time.Unix(1700000000, 0)},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return desc, string(b)
+	}
+
+	desc1, index := build([]types.Manifest{attestation(s390x), arm64, amd64, s390x, attestation(amd64)}, nil)
+	desc2, _ := build([]types.Manifest{s390x, attestation(amd64), amd64, attestation(s390x), arm64}, nil)
+	if desc1.Digest != desc2.Digest {
+		t.Errorf("the order of the manifests changes the digest: %s and %s", desc1.Digest, desc2.Digest)
+	}
+	if !strings.HasPrefix(index, `{"annotations":{"a":"1","b":"<2>","org.opencontainers.image.created":"2023-11-14T22:13:20Z"},"manifests":[`) {
+		t.Errorf("unexpected canonical index %s", index)
+	}
+	want := []string{amd64.Descriptor.Digest.String(), arm64.Descriptor.Digest.String(), s390x.Descriptor.Digest.String(),
+		attestation(amd64).Descriptor.Digest.String(), attestation(s390x).Descriptor.Digest.String()}
+	assertOrder(t, index, want)
+
+	_, index = build([]types.Manifest{attestation(s390x), arm64, amd64, s390x, attestation(amd64)}, []ocispec.Platform{{OS: "linux", Architecture: "s390x"}, {OS: "linux", Architecture: "arm64"}})
+	want = []string{s390x.Descriptor.Digest.String(), arm64.Descriptor.Digest.String(), amd64.Descriptor.Digest.String(),
+		attestation(s390x).Descriptor.Digest.String(), attestation(amd64).Descriptor.Digest.String()}
+	assertOrder(t, index, want)
+}
+
+func assertOrder(t *testing.T, index string, digests []string) {
+	t.Helper()
+	last := -1
+	for _, d := range digests {
+		i := strings.Index(index, `"digest":"`+d+`"`)
+		if i < last {
+			t.Errorf("unexpected order of manifests in %s; expected %v", index, digests)
+			return
+		}
+		last = i
+	}
+}
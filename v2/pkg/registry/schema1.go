@@ -0,0 +1,230 @@
+package registry
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/containerd/containerd/v2/core/remotes"
+	"github.com/docker/distribution/reference"
+	"github.com/estesp/manifest-tool/v2/pkg/store"
+	"github.com/estesp/manifest-tool/v2/pkg/types"
+	"github.com/opencontainers/go-digest"
+	specs "github.com/opencontainers/image-spec/specs-go"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/sirupsen/logrus"
+)
+
+// ParseSchema1 decodes a Docker schema 1 manifest and the v1 image JSON of each of its history
+// entries, which are ordered from the top layer down to the base layer like the manifest layers
+func ParseSchema1(content []byte) (types.Schema1Manifest, []types.Schema1Image, error) {
+	var man types.Schema1Manifest
+	if err := json.Unmarshal(content, &man); err != nil {
+		return man, nil, err
+	}
+	if len(man.FSLayers) != len(man.History) {
+		return man, nil, fmt.Errorf("schema 1 manifest has %d layers but %d history entries", len(man.FSLayers), len(man.History))
+	}
+	v1Images := make([]types.Schema1Image, len(man.History))
+	for i, h := range man.History {
+		if err := json.Unmarshal([]byte(h.V1Compatibility), &v1Images[i]); err != nil {
+			return man, nil, fmt.Errorf("could not unmarshal v1 image JSON of history entry %d: %v", i, err)
+		}
+	}
+	return man, v1Images, nil
+}
+
+// Schema1Layers returns the digests of the layers of a schema 1 image which change the
+// filesystem, ordered from the base layer up like the layers of an image manifest
+func Schema1Layers(man types.Schema1Manifest, v1Images []types.Schema1Image) []digest.Digest {
+	var layers []digest.Digest
+	for i := len(v1Images) - 1; i >= 0; i-- {
+		if !emptySchema1Layer(man, v1Images, i) {
+			layers = append(layers, man.FSLayers[i].BlobSum)
+		}
+	}
+	return layers
+}
+
+// emptySchema1Layer reports whether the i-th layer of a schema 1 image leaves the filesystem
+// unchanged: either its history entry is marked as throwaway or, as in manifests written
+// before the throwaway flag existed, the layer is the well-known empty layer
+func emptySchema1Layer(man types.Schema1Manifest, v1Images []types.Schema1Image, i int) bool {
+	return v1Images[i].ThrowAway || man.FSLayers[i].BlobSum == types.EmptySchema1Layer
+}
+
+// Schema1Config synthesizes an image config from the v1 image JSON of a schema 1 image: the
+// platform and execution parameters of the top layer and the history of all layers. The
+// root filesystem (uncompressed layer digests) cannot be determined from the manifest.
+func Schema1Config(man types.Schema1Manifest, v1Images []types.Schema1Image) ocispec.Image {
+	var config ocispec.Image
+	if len(v1Images) == 0 {
+		return config
+	}
+	top := v1Images[0]
+	config.Created = top.Created
+	config.Author = top.Author
+	config.OS = top.OS
+	config.Architecture = top.Architecture
+	config.Variant = top.Variant
+	if config.OS == "" {
+		config.OS = "linux"
+	}
+	if config.Architecture == "" {
+		config.Architecture = man.Architecture
+	}
+	if top.Config != nil {
+		config.Config = *top.Config
+	}
+	for i := len(v1Images) - 1; i >= 0; i-- {
+		config.History = append(config.History, ocispec.History{
+			Created:    v1Images[i].Created,
+			CreatedBy:  strings.Join(v1Images[i].ContainerConfig.Cmd, " "),
+			Author:     v1Images[i].Author,
+			Comment:    v1Images[i].Comment,
+			EmptyLayer: emptySchema1Layer(man, v1Images, i),
+		})
+	}
+	return config
+}
+
+// convertSchema1 converts the Docker schema 1 manifest described by desc, which has already been
+// fetched into the content store, into a Docker schema 2 or OCI image manifest (depending on the
+// manifest type) with an image config synthesized from the v1 image history. Schema 1 manifests do
+// not record layer sizes or uncompressed digests, so every layer is retrieved from the registry to
+// determine them. The new manifest and config are added to the content store and the returned
+// descriptor for the new manifest includes the image platform.
+func convertSchema1(ctx context.Context, cs store.ContentStore, resolver remotes.Resolver, ref reference.Named, desc ocispec.Descriptor, manifestType types.ManifestType) (ocispec.Descriptor, error) {
+	_, db, _ := cs.Get(desc)
+	s1, v1Images, err := ParseSchema1(db)
+	if err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("could not parse schema 1 manifest %s: %v", desc.Digest.String(), err)
+	}
+	if len(v1Images) == 0 {
+		return ocispec.Descriptor{}, fmt.Errorf("schema 1 manifest %s has no layers", desc.Digest.String())
+	}
+	fetcher, err := resolver.Fetcher(ctx, ref.String())
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+
+	manifestMediaType, configMediaType, layerMediaType := types.MediaTypeDockerSchema2Manifest, types.MediaTypeDockerSchema2Config, types.MediaTypeDockerTarGzipLayer
+	if manifestType == types.OCI {
+		manifestMediaType, configMediaType, layerMediaType = ocispec.MediaTypeImageManifest, ocispec.MediaTypeImageConfig, ocispec.MediaTypeImageLayerGzip
+	}
+
+	var (
+		layers  []ocispec.Descriptor
+		diffIDs []digest.Digest
+	)
+	for _, blobSum := range Schema1Layers(s1, v1Images) {
+		logrus.Debugf("retrieving layer %s of schema 1 image %s", blobSum, ref)
+		size, diffID, err := layerDigests(ctx, fetcher, blobSum)
+		if err != nil {
+			return ocispec.Descriptor{}, fmt.Errorf("could not retrieve layer %s of schema 1 image %s: %w", blobSum, ref, err)
+		}
+		layers = append(layers, ocispec.Descriptor{
+			MediaType: layerMediaType,
+			Digest:    blobSum,
+			Size:      size,
+		})
+		diffIDs = append(diffIDs, diffID)
+	}
+
+	config := Schema1Config(s1, v1Images)
+	config.RootFS = ocispec.RootFS{
+		Type:    "layers",
+		DiffIDs: diffIDs,
+	}
+	cb, err := json.Marshal(config)
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+	configDesc := ocispec.Descriptor{
+		MediaType: configMediaType,
+		Digest:    digest.FromBytes(cb),
+		Size:      int64(len(cb)),
+	}
+	if err := cs.Set(configDesc, cb); err != nil {
+		return ocispec.Descriptor{}, err
+	}
+
+	man := ocispec.Manifest{
+		Versioned: specs.Versioned{
+			SchemaVersion: 2,
+		},
+		MediaType: manifestMediaType,
+		Config:    configDesc,
+		Layers:    layers,
+	}
+	mb, err := json.MarshalIndent(man, "", "  ")
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+	manDesc := ocispec.Descriptor{
+		MediaType: manifestMediaType,
+		Digest:    digest.FromBytes(mb),
+		Size:      int64(len(mb)),
+		Platform:  &config.Platform,
+	}
+	if err := cs.Set(manDesc, mb); err != nil {
+		return ocispec.Descriptor{}, err
+	}
+
+	// the layers are mounted from the source repository, so the new manifest carries the
+	// distribution source labels of the schema 1 manifest for setLayerLabels to copy
+	info, _ := cs.Info(ctx, desc.Digest)
+	info.Digest = manDesc.Digest
+	if _, err := cs.Update(ctx, info); err != nil {
+		return ocispec.Descriptor{}, err
+	}
+	logrus.Infof("converted schema 1 image %s (%s) to manifest %s", ref, desc.Digest, manDesc.Digest)
+	return manDesc, nil
+}
+
+// layerDigests retrieves a gzipped layer to determine its size and the digest of its
+// uncompressed content (the diff ID of an image config), verifying the layer digest
+func layerDigests(ctx context.Context, fetcher remotes.Fetcher, dgst digest.Digest) (int64, digest.Digest, error) {
+	// the layer size is unknown (-1) until it has been read
+	rc, err := fetcher.Fetch(ctx, ocispec.Descriptor{
+		MediaType: types.MediaTypeDockerTarGzipLayer,
+		Digest:    dgst,
+		Size:      -1,
+	})
+	if err != nil {
+		return 0, "", err
+	}
+	defer rc.Close() //nolint:errcheck
+
+	verifier := dgst.Verifier()
+	counter := &byteCounter{}
+	compressed := io.TeeReader(rc, io.MultiWriter(verifier, counter))
+	zr, err := gzip.NewReader(compressed)
+	if err != nil {
+		return 0, "", err
+	}
+	diffID, err := digest.FromReader(zr)
+	if err != nil {
+		return 0, "", err
+	}
+	// read any remaining data after the end of the gzip stream so that it is verified as well
+	if _, err := io.Copy(io.Discard, compressed); err != nil {
+		return 0, "", err
+	}
+	if !verifier.Verified() {
+		return 0, "", fmt.Errorf("content of layer %s does not match its digest", dgst)
+	}
+	return counter.n, diffID, nil
+}
+
+type byteCounter struct {
+	n int64
+}
+
+func (c *byteCounter) Write(p []byte) (int, error) {
+	c.n += int64(len(p))
+	return len(p), nil
+}
@@ -0,0 +1,39 @@
+package registry
+
+import (
+	"testing"
+
+	"github.com/estesp/manifest-tool/v2/pkg/types"
+	"github.com/opencontainers/go-digest"
+)
+
+func TestSchema1Layers(t *testing.T) {
+	base, top := digest.FromString("base"), digest.FromString("top")
+	man := types.Schema1Manifest{
+		FSLayers: []types.Schema1FSLayer{
+			{BlobSum: top},
+			{BlobSum: types.EmptySchema1Layer},
+			{BlobSum: digest.FromString("throwaway")},
+			{BlobSum: base},
+		},
+	}
+	v1Images := []types.Schema1Image{
+		{ID: "top"},
+		// an empty layer of an older manifest without the throwaway flag
+		{ID: "cmd"},
+		{ID: "env", ThrowAway: true},
+		{ID: "base"},
+	}
+	layers := Schema1Layers(man, v1Images)
+	if len(layers) != 2 || layers[0] != base || layers[1] != top {
+		t.Fatalf("unexpected layers %v", layers)
+	}
+	config := Schema1Config(man, v1Images)
+	var empty []bool
+	for _, h := range config.History {
+		empty = append(empty, h.EmptyLayer)
+	}
+	if len(empty) != 4 || empty[0] || !empty[1] || !empty[2] || empty[3] {
+		t.Fatalf("unexpected empty layer history %v", empty)
+	}
+}
@@ -0,0 +1,126 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/sirupsen/logrus"
+)
+
+// DiskCache is a persistent cache of content (manifests, configs and other
+// small blobs retrieved by manifest-tool) keyed by digest, so that immutable
+// content is only retrieved from a registry once. The descriptor of each blob
+// is kept next to its content, so that an image reference by digest can be
+// resolved without a registry request.
+//
+// Layout: <root>/blobs/<algorithm>/<encoded> holds the content and
+// <root>/blobs/<algorithm>/<encoded>.json its descriptor.
+type DiskCache struct {
+	root string
+}
+
+// NewDiskCache creates a cache in the root directory, creating it if needed
+func NewDiskCache(root string) (*DiskCache, error) {
+	if err := os.MkdirAll(filepath.Join(root, "blobs"), 0o755); err != nil {
+		return nil, fmt.Errorf("cannot create content cache directory: %w", err)
+	}
+	return &DiskCache{root: root}, nil
+}
+
+func (c *DiskCache) path(d digest.Digest) (string, error) {
+	if err := d.Validate(); err != nil {
+		return "", err
+	}
+	return filepath.Join(c.root, "blobs", d.Algorithm().String(), d.Encoded()), nil
+}
+
+// Get returns the cached content for a digest; content which no longer matches
+// its digest (e.g. a corrupted file) is removed and reported as missing
+func (c *DiskCache) Get(d digest.Digest) ([]byte, bool) {
+	p, err := c.path(d)
+	if err != nil {
+		return nil, false
+	}
+	content, err := os.ReadFile(p)
+	if err != nil {
+		return nil, false
+	}
+	if d.Algorithm().FromBytes(content) != d {
+		logrus.Warnf("removing cached content %s which does not match its digest", d)
+		_ = os.Remove(p)
+		_ = os.Remove(p + ".json")
+		return nil, false
+	}
+	return content, true
+}
+
+// Descriptor returns the cached descriptor for a digest
+func (c *DiskCache) Descriptor(d digest.Digest) (ocispec.Descriptor, bool) {
+	p, err := c.path(d)
+	if err != nil {
+		return ocispec.Descriptor{}, false
+	}
+	b, err := os.ReadFile(p + ".json")
+	if err != nil {
+		return ocispec.Descriptor{}, false
+	}
+	var desc ocispec.Descriptor
+	if err := json.Unmarshal(b, &desc); err != nil || desc.Digest != d {
+		return ocispec.Descriptor{}, false
+	}
+	return desc, true
+}
+
+// Put adds content which has been verified against its descriptor to the cache
+func (c *DiskCache) Put(desc ocispec.Descriptor, content []byte) error {
+	p, err := c.path(desc.Digest)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return err
+	}
+	// only the properties of the content itself are kept, not those of a reference to it
+	db, err := json.Marshal(ocispec.Descriptor{
+		MediaType:    desc.MediaType,
+		ArtifactType: desc.ArtifactType,
+		Digest:       desc.Digest,
+		Size:         desc.Size,
+	})
+	if err != nil {
+		return err
+	}
+	// the content is written before its descriptor, so that a cached descriptor
+	// always has its content available
+	if err := writeFile(p, content); err != nil {
+		return err
+	}
+	return writeFile(p+".json", db)
+}
+
+// writeFile writes a file atomically (via a rename) so that concurrent
+// invocations sharing the cache never read partial content
+func writeFile(path string, content []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(path), ".tmp-")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(content); err != nil {
+		f.Close()           //nolint:errcheck
+		os.Remove(f.Name()) //nolint:errcheck
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name()) //nolint:errcheck
+		return err
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		os.Remove(f.Name()) //nolint:errcheck
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package store
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	ccontent "github.com/containerd/containerd/v2/core/content"
+	"github.com/containerd/errdefs"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+func TestCachedMemoryStore(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	content := []byte(`{"architecture":"arm64","os":"linux"}`)
+	desc := descriptor(ocispec.MediaTypeImageConfig, content)
+
+	ms, err := NewCachedMemoryStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ms.Set(desc, content)
+
+	// a new store (i.e. a later invocation) finds the content in the cache
+	ms, err = NewCachedMemoryStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cached, ok := ms.CachedDescriptor(desc.Digest); !ok || cached.MediaType != desc.MediaType || cached.Size != desc.Size {
+		t.Errorf("cached descriptor: got %v (found %v)", cached, ok)
+	}
+	if _, got, found := ms.Get(desc); !found || string(got) != string(content) {
+		t.Errorf("cached content: got %q (found %v)", got, found)
+	}
+	if _, err := ms.Writer(ctx, ccontent.WithRef("config"), ccontent.WithDescriptor(desc)); !errdefs.IsAlreadyExists(err) {
+		t.Errorf("writer for cached content: got %v, want an already exists error", err)
+	}
+
+	// corrupted content is removed from the cache
+	path := filepath.Join(dir, "blobs", desc.Digest.Algorithm().String(), desc.Digest.Encoded())
+	if err := os.WriteFile(path, []byte("corrupted"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	ms, err = NewCachedMemoryStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, found := ms.Get(desc); found {
+		t.Error("corrupted cached content was returned")
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("corrupted cached content was not removed: %v", err)
+	}
+}
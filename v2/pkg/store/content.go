@@ -0,0 +1,148 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	ccontent "github.com/containerd/containerd/v2/core/content"
+	"github.com/containerd/errdefs"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// ensure interface
+var (
+	_ ContentStore    = &MemoryStore{}
+	_ ContentStore    = &contentStore{}
+	_ DescriptorCache = &MemoryStore{}
+)
+
+// ContentStore is the content store used to fetch and push images: a containerd
+// content store (with labels carrying the distribution source of content) along
+// with the direct content and name lookups manifest-tool needs. A MemoryStore
+// implements it, and NewContentStore adapts any other containerd content store.
+type ContentStore interface {
+	ccontent.Store
+
+	// Get returns the content for a specific descriptor, if it is in the store
+	Get(desc ocispec.Descriptor) (ocispec.Descriptor, []byte, bool)
+	// Set adds the content for a specific descriptor, returning an error if the
+	// content does not match the descriptor
+	Set(desc ocispec.Descriptor, content []byte) error
+	// SetDescriptor records a descriptor for content which is not retrieved (such
+	// as a layer), so that its details and labels are available for pushing
+	// references to it
+	SetDescriptor(desc ocispec.Descriptor)
+	// GetByName returns the descriptor and content stored with a reference name annotation
+	GetByName(name string) (ocispec.Descriptor, []byte, bool)
+}
+
+// DescriptorCache is implemented by content stores which can provide the
+// descriptor of content they hold by digest, so that a reference by digest
+// can be resolved without a registry
+type DescriptorCache interface {
+	CachedDescriptor(d digest.Digest) (ocispec.Descriptor, bool)
+}
+
+// contentStore adapts a containerd content store to a ContentStore. Labels
+// and descriptors of content which is not in the store (such as layers which
+// are mounted from another repository rather than retrieved) are kept in memory.
+type contentStore struct {
+	ccontent.Store
+	overlay *MemoryStore
+	l       sync.RWMutex
+	nameMap map[string]ocispec.Descriptor
+}
+
+// NewContentStore adapts a containerd content store (such as containerd's local
+// store or an OCI layout store) for use by manifest-tool
+func NewContentStore(cs ccontent.Store) ContentStore {
+	return &contentStore{
+		Store:   cs,
+		overlay: NewMemoryStore(),
+		nameMap: map[string]ocispec.Descriptor{},
+	}
+}
+
+// Info returns the info for a digest from the store or, for content which is
+// not in the store, the labels set for it
+func (c *contentStore) Info(ctx context.Context, d digest.Digest) (ccontent.Info, error) {
+	info, err := c.Store.Info(ctx, d)
+	if errdefs.IsNotFound(err) {
+		return c.overlay.Info(ctx, d)
+	}
+	return info, err
+}
+
+// Update updates the labels of content in the store, or keeps them in memory
+// for content which is not in the store
+func (c *contentStore) Update(ctx context.Context, info ccontent.Info, fieldpaths ...string) (ccontent.Info, error) {
+	updated, err := c.Store.Update(ctx, info, fieldpaths...)
+	if errdefs.IsNotFound(err) {
+		return c.overlay.Update(ctx, info, fieldpaths...)
+	}
+	return updated, err
+}
+
+// Delete removes content from the store along with any labels kept in memory
+func (c *contentStore) Delete(ctx context.Context, d digest.Digest) error {
+	err := c.Store.Delete(ctx, d)
+	if oerr := c.overlay.Delete(ctx, d); oerr == nil && errdefs.IsNotFound(err) {
+		err = nil
+	}
+	c.l.Lock()
+	for name, desc := range c.nameMap {
+		if desc.Digest == d {
+			delete(c.nameMap, name)
+		}
+	}
+	c.l.Unlock()
+	return err
+}
+
+// Get returns the content for a specific descriptor
+func (c *contentStore) Get(desc ocispec.Descriptor) (ocispec.Descriptor, []byte, bool) {
+	content, err := ccontent.ReadBlob(context.Background(), c.Store, desc)
+	if err != nil {
+		return desc, nil, false
+	}
+	return desc, content, true
+}
+
+// Set writes the content for a specific descriptor to the store
+func (c *contentStore) Set(desc ocispec.Descriptor, content []byte) error {
+	if err := verifyContent(desc, content); err != nil {
+		return err
+	}
+	if err := ccontent.WriteBlob(context.Background(), c.Store, desc.Digest.String(), bytes.NewReader(content), desc); err != nil && !errors.Is(err, errdefs.ErrAlreadyExists) {
+		return fmt.Errorf("error writing %s to the content store: %w", desc.Digest, err)
+	}
+	if name, ok := resolveName(desc); ok {
+		c.l.Lock()
+		c.nameMap[name] = desc
+		c.l.Unlock()
+	}
+	return nil
+}
+
+// SetDescriptor records a descriptor for content which is not in the store
+func (c *contentStore) SetDescriptor(desc ocispec.Descriptor) {
+	if _, err := c.Store.Info(context.Background(), desc.Digest); err == nil {
+		return
+	}
+	c.overlay.SetDescriptor(desc)
+}
+
+// GetByName retrieves a descriptor based on the associated name
+func (c *contentStore) GetByName(name string) (ocispec.Descriptor, []byte, bool) {
+	c.l.RLock()
+	desc, found := c.nameMap[name]
+	c.l.RUnlock()
+	if !found {
+		return desc, nil, false
+	}
+	return c.Get(desc)
+}
@@ -0,0 +1,124 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	ccontent "github.com/containerd/containerd/v2/core/content"
+	"github.com/containerd/errdefs"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// contentOnlyStore only keeps labels for content it holds, like containerd's local store
+type contentOnlyStore struct {
+	*MemoryStore
+}
+
+func (s contentOnlyStore) Info(ctx context.Context, d digest.Digest) (ccontent.Info, error) {
+	if _, _, ok := s.Get(ocispec.Descriptor{Digest: d}); !ok {
+		return ccontent.Info{}, fmt.Errorf("content %v: %w", d, errdefs.ErrNotFound)
+	}
+	return s.MemoryStore.Info(ctx, d)
+}
+
+func (s contentOnlyStore) Update(ctx context.Context, info ccontent.Info, fieldpaths ...string) (ccontent.Info, error) {
+	if _, _, ok := s.Get(ocispec.Descriptor{Digest: info.Digest}); !ok {
+		return ccontent.Info{}, fmt.Errorf("content %v: %w", info.Digest, errdefs.ErrNotFound)
+	}
+	return s.MemoryStore.Update(ctx, info, fieldpaths...)
+}
+
+// readOnlyStore fails to open writers, like a content store without write access
+type readOnlyStore struct {
+	*MemoryStore
+}
+
+func (s readOnlyStore) Writer(ctx context.Context, opts ...ccontent.WriterOpt) (ccontent.Writer, error) {
+	return nil, fmt.Errorf("store is read-only: %w", errdefs.ErrPermissionDenied)
+}
+
+func TestContentStore(t *testing.T) {
+	ctx := context.Background()
+	backing := contentOnlyStore{NewMemoryStore()}
+	cs := NewContentStore(backing)
+
+	manifest := []byte(`{"schemaVersion":2}`)
+	desc := descriptor(ocispec.MediaTypeImageManifest, manifest)
+	desc.Annotations = map[string]string{ocispec.AnnotationRefName: "example.com/test:1"}
+	if err := cs.Set(desc, manifest); err != nil {
+		t.Fatal(err)
+	}
+	if _, content, ok := backing.Get(desc); !ok || string(content) != string(manifest) {
+		t.Fatalf("content not written to the backing store: %q", content)
+	}
+	if _, content, ok := cs.Get(desc); !ok || string(content) != string(manifest) {
+		t.Fatalf("unexpected content %q", content)
+	}
+	if named, _, ok := cs.GetByName("example.com/test:1"); !ok || named.Digest != desc.Digest {
+		t.Fatalf("unexpected descriptor by name %v", named)
+	}
+	// content already in the backing store is not an error
+	if err := cs.Set(desc, manifest); err != nil {
+		t.Fatalf("unexpected error setting existing content: %v", err)
+	}
+	// mismatched content is an error and is not stored
+	if err := cs.Set(descriptor(ocispec.MediaTypeImageConfig, []byte("other")), []byte("wrong")); !errdefs.IsFailedPrecondition(err) {
+		t.Fatalf("expected a failed precondition error for mismatched content, got %v", err)
+	}
+	if _, _, ok := cs.Get(descriptor(ocispec.MediaTypeImageConfig, []byte("other"))); ok {
+		t.Fatal("mismatched content should not be stored")
+	}
+
+	// labels of content in the backing store are kept there
+	if _, err := cs.Update(ctx, ccontent.Info{Digest: desc.Digest, Labels: map[string]string{"a": "b"}}, "labels.a"); err != nil {
+		t.Fatal(err)
+	}
+	if info, _ := backing.Info(ctx, desc.Digest); info.Labels["a"] != "b" {
+		t.Fatalf("unexpected backing store labels %v", info.Labels)
+	}
+
+	// descriptors and labels of layers are kept in memory
+	layer := ocispec.Descriptor{MediaType: ocispec.MediaTypeImageLayerGzip, Digest: digest.FromString("layer"), Size: 5}
+	cs.SetDescriptor(layer)
+	if _, err := cs.Update(ctx, ccontent.Info{Digest: layer.Digest, Labels: map[string]string{"a": "c"}}, "labels.a"); err != nil {
+		t.Fatal(err)
+	}
+	info, err := cs.Info(ctx, layer.Digest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size != 5 || info.Labels["a"] != "c" {
+		t.Fatalf("unexpected layer info %v", info)
+	}
+	if _, err := backing.Info(ctx, layer.Digest); !errdefs.IsNotFound(err) {
+		t.Fatalf("expected layer to be missing from the backing store, got %v", err)
+	}
+
+	if err := cs.Delete(ctx, layer.Digest); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cs.Info(ctx, layer.Digest); !errdefs.IsNotFound(err) {
+		t.Fatalf("expected not found after delete, got %v", err)
+	}
+	if err := cs.Delete(ctx, desc.Digest); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, ok := cs.GetByName("example.com/test:1"); ok {
+		t.Fatal("name should be removed with its content")
+	}
+}
+
+func TestContentStoreWriteError(t *testing.T) {
+	cs := NewContentStore(readOnlyStore{NewMemoryStore()})
+	manifest := []byte(`{"schemaVersion":2}`)
+	desc := descriptor(ocispec.MediaTypeImageManifest, manifest)
+	desc.Annotations = map[string]string{ocispec.AnnotationRefName: "example.com/test:1"}
+	if err := cs.Set(desc, manifest); !errdefs.IsPermissionDenied(err) {
+		t.Fatalf("expected the write error to be returned, got %v", err)
+	}
+	if _, _, ok := cs.GetByName("example.com/test:1"); ok {
+		t.Fatal("name should not be recorded for content which was not written")
+	}
+}
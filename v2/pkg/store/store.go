@@ -5,14 +5,18 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	ccontent "github.com/containerd/containerd/v2/core/content"
+	"github.com/containerd/containerd/v2/pkg/filters"
 	"github.com/containerd/errdefs"
 	"github.com/opencontainers/go-digest"
 	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
-	"oras.land/oras-go/v2/content/memory"
+	"github.com/sirupsen/logrus"
 )
 
 // ensure interface
@@ -28,12 +32,27 @@ type labelStore struct {
 	labels map[digest.Digest]map[string]string
 }
 
+// blob holds content (such as a manifest or config) which has been stored
+type blob struct {
+	content   []byte
+	createdAt time.Time
+	updatedAt time.Time
+}
+
 // MemoryStore implements a simple in-memory content store for labels and
-// descriptors (and associated content for manifests and configs)
+// descriptors (and associated content for manifests and configs). Content
+// is kept as the byte slice it was stored with and is served without copies,
+// so callers must not modify stored or retrieved content. Descriptors of
+// content which is never retrieved (such as layers) are kept in a separate
+// table without any content.
 type MemoryStore struct {
-	store   *memory.Store
-	labels  labelStore
-	nameMap map[string]ocispec.Descriptor
+	l           sync.RWMutex
+	blobs       map[digest.Digest]*blob
+	descriptors map[digest.Digest]ocispec.Descriptor
+	ingests     map[string]*memoryWriter
+	labels      labelStore
+	nameMap     map[string]ocispec.Descriptor
+	cache       *DiskCache
 }
 
 func newLabelStore() labelStore {
@@ -47,87 +66,216 @@ func newLabelStore() labelStore {
 // containerd's content in a memory-only context
 func NewMemoryStore() *MemoryStore {
 	return &MemoryStore{
-		store:   memory.New(),
-		labels:  newLabelStore(),
-		nameMap: map[string]ocispec.Descriptor{},
+		blobs:       map[digest.Digest]*blob{},
+		descriptors: map[digest.Digest]ocispec.Descriptor{},
+		ingests:     map[string]*memoryWriter{},
+		labels:      newLabelStore(),
+		nameMap:     map[string]ocispec.Descriptor{},
+	}
+}
+
+// NewCachedMemoryStore creates a memory store backed by a persistent content
+// cache in the directory: content missing from the memory store is read from
+// the cache, and content added to the memory store is added to the cache. An
+// empty directory creates a memory store without a cache.
+func NewCachedMemoryStore(cacheDir string) (*MemoryStore, error) {
+	ms := NewMemoryStore()
+	if cacheDir == "" {
+		return ms, nil
+	}
+	cache, err := NewDiskCache(cacheDir)
+	if err != nil {
+		return nil, err
 	}
+	ms.cache = cache
+	return ms, nil
 }
 
-// Update updates mutable label field content related to a descriptor
+// CachedDescriptor returns the descriptor of content in the persistent cache
+// of the store, so that a reference by digest can be resolved without a registry
+func (m *MemoryStore) CachedDescriptor(d digest.Digest) (ocispec.Descriptor, bool) {
+	if m.cache == nil {
+		return ocispec.Descriptor{}, false
+	}
+	desc, ok := m.cache.Descriptor(d)
+	if !ok {
+		return ocispec.Descriptor{}, false
+	}
+	// make sure the content is available as well
+	if _, ok := m.content(desc); !ok {
+		return ocispec.Descriptor{}, false
+	}
+	return desc, true
+}
+
+// Update updates mutable label field content related to a descriptor. Labels
+// may also be set for a digest without content in the store (such as a layer)
+// to carry distribution source details. Without field paths, the given labels
+// are merged into the existing labels (an empty value removes a label);
+// otherwise only the "labels" or "labels.<key>" field paths are updated.
 func (m *MemoryStore) Update(ctx context.Context, info ccontent.Info, fieldpaths ...string) (ccontent.Info, error) {
-	newLabels, err := m.update(info.Digest, info.Labels)
+	newLabels, err := m.update(info.Digest, info.Labels, fieldpaths...)
 	if err != nil {
-		return ccontent.Info{}, nil
+		return ccontent.Info{}, err
+	}
+	m.l.Lock()
+	if b, ok := m.blobs[info.Digest]; ok {
+		b.updatedAt = time.Now()
 	}
+	m.l.Unlock()
 	info.Labels = newLabels
 	return info, nil
 }
 
-// Walk is unimplemented
-func (m *MemoryStore) Walk(ctx context.Context, fn ccontent.WalkFunc, filters ...string) error {
-	// unimplemented
+// Walk calls fn for the info of each blob of content in the store which matches
+// any of the filters (on "digest", "size" or "labels.<key>"), in digest order
+func (m *MemoryStore) Walk(ctx context.Context, fn ccontent.WalkFunc, fs ...string) error {
+	filter, err := filters.ParseAll(fs...)
+	if err != nil {
+		return fmt.Errorf("invalid content filter: %v: %w", err, errdefs.ErrInvalidArgument)
+	}
+	m.l.RLock()
+	dgsts := make([]digest.Digest, 0, len(m.blobs))
+	for d := range m.blobs {
+		dgsts = append(dgsts, d)
+	}
+	m.l.RUnlock()
+	sort.Slice(dgsts, func(i, j int) bool { return dgsts[i] < dgsts[j] })
+
+	for _, d := range dgsts {
+		info, err := m.Info(ctx, d)
+		if err != nil {
+			if errdefs.IsNotFound(err) {
+				// deleted while walking
+				continue
+			}
+			return err
+		}
+		if !filter.Match(adaptInfo(info)) {
+			continue
+		}
+		if err := fn(info); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
-func (m *MemoryStore) update(d digest.Digest, update map[string]string) (map[string]string, error) {
+func (m *MemoryStore) update(d digest.Digest, update map[string]string, fieldpaths ...string) (map[string]string, error) {
+	if d == "" {
+		return nil, fmt.Errorf("cannot update labels without a digest: %w", errdefs.ErrInvalidArgument)
+	}
+	keys := map[string]struct{}{}
+	all := len(fieldpaths) == 0
+	for _, path := range fieldpaths {
+		switch {
+		case path == "labels":
+			all = true
+		case strings.HasPrefix(path, "labels."):
+			keys[strings.TrimPrefix(path, "labels.")] = struct{}{}
+		default:
+			return nil, fmt.Errorf("cannot update %q field on content info %v: %w", path, d, errdefs.ErrInvalidArgument)
+		}
+	}
+	if all {
+		for k := range update {
+			keys[k] = struct{}{}
+		}
+	}
+
 	m.labels.l.Lock()
+	defer m.labels.l.Unlock()
 	labels, ok := m.labels.labels[d]
 	if !ok {
 		labels = map[string]string{}
 	}
-	for k, v := range update {
-		if v == "" {
+	for k := range keys {
+		if v := update[k]; v == "" {
 			delete(labels, k)
 		} else {
 			labels[k] = v
 		}
 	}
 	m.labels.labels[d] = labels
-	m.labels.l.Unlock()
 
-	return labels, nil
+	return copyLabels(labels), nil
 }
 
-// Delete is unimplemented as we don't use it in the flow of manifest-tool
+// Delete removes the content or descriptor-only entry for a digest, along with
+// its labels and any names which refer to it
 func (m *MemoryStore) Delete(ctx context.Context, d digest.Digest) error {
+	m.l.Lock()
+	defer m.l.Unlock()
+	_, isBlob := m.blobs[d]
+	_, isDescriptor := m.descriptors[d]
+	if !isBlob && !isDescriptor {
+		return fmt.Errorf("content %v: %w", d, errdefs.ErrNotFound)
+	}
+	delete(m.blobs, d)
+	delete(m.descriptors, d)
+	for name, desc := range m.nameMap {
+		if desc.Digest == d {
+			delete(m.nameMap, name)
+		}
+	}
+
+	m.labels.l.Lock()
+	delete(m.labels.labels, d)
+	m.labels.l.Unlock()
 	return nil
 }
 
-// Info returns the info for a specific digest
+// Info returns the info for a specific digest. A descriptor-only entry returns
+// the size of its descriptor, and a digest which only has labels set returns
+// info with only its labels.
 func (m *MemoryStore) Info(ctx context.Context, d digest.Digest) (ccontent.Info, error) {
-	m.labels.l.RLock()
+	// load the content from the cache, if it has not been used yet
+	_, _ = m.content(ocispec.Descriptor{Digest: d})
+	m.l.RLock()
 	info := ccontent.Info{
 		Digest: d,
-		Labels: m.labels.labels[d],
 	}
+	b, found := m.blobs[d]
+	if found {
+		info.Size = int64(len(b.content))
+		info.CreatedAt = b.createdAt
+		info.UpdatedAt = b.updatedAt
+	} else if desc, ok := m.descriptors[d]; ok {
+		info.Size = desc.Size
+		found = true
+	}
+	m.l.RUnlock()
+
+	m.labels.l.RLock()
+	labels, ok := m.labels.labels[d]
+	info.Labels = copyLabels(labels)
 	m.labels.l.RUnlock()
+	if !found && !ok {
+		return ccontent.Info{}, fmt.Errorf("content %v: %w", d, errdefs.ErrNotFound)
+	}
 	return info, nil
 }
 
-// ReaderAt returns a reader for a descriptor
+// ReaderAt returns a reader for the content of a descriptor
 func (m *MemoryStore) ReaderAt(ctx context.Context, desc ocispec.Descriptor) (ccontent.ReaderAt, error) {
-	rc, err := m.store.Fetch(context.Background(), desc)
-	if err != nil {
-		return nil, errdefs.ErrNotFound
-	}
-	defer rc.Close() //nolint:errcheck
-
-	var buf bytes.Buffer
-	if _, err := io.Copy(&buf, rc); err != nil {
-		return nil, err
+	content, ok := m.content(desc)
+	if !ok {
+		return nil, fmt.Errorf("content %v: %w", desc.Digest, errdefs.ErrNotFound)
 	}
-
 	return sizeReaderAt{
 		readAtCloser: nopCloser{
-			ReaderAt: bytes.NewReader(buf.Bytes()),
+			ReaderAt: bytes.NewReader(content),
 		},
-		size: desc.Size,
+		size: int64(len(content)),
 	}, nil
 }
 
-// Writer returns a content writer given the specific options
+// Writer returns a content writer given the specific options. The ingest is
+// tracked by its ref (required) until it is committed, closed or aborted; a
+// ref which is already being written returns an "unavailable" error and
+// content which is already in the store returns an "already exists" error.
 func (m *MemoryStore) Writer(ctx context.Context, opts ...ccontent.WriterOpt) (ccontent.Writer, error) {
-	// this function is the original `Writer` implementation from oras 0.9.x, copied as-is
+	// this function is based on the original `Writer` implementation from oras 0.9.x
 	// given that oras-go v1.2.x has changed the signature and the implementation under a "Pusher" method
 	var wOpts ccontent.WriterOpts
 	for _, opt := range opts {
@@ -135,70 +283,248 @@ func (m *MemoryStore) Writer(ctx context.Context, opts ...ccontent.WriterOpt) (c
 			return nil, err
 		}
 	}
+	if wOpts.Ref == "" {
+		return nil, fmt.Errorf("ref must not be empty: %w", errdefs.ErrInvalidArgument)
+	}
 	desc := wOpts.Desc
 
-	name, _ := resolveName(desc)
+	if desc.Digest != "" {
+		if _, ok := m.content(desc); ok {
+			return nil, fmt.Errorf("content %v: %w", desc.Digest, errdefs.ErrAlreadyExists)
+		}
+	}
+	m.l.Lock()
+	defer m.l.Unlock()
+	if _, ok := m.ingests[wOpts.Ref]; ok {
+		return nil, fmt.Errorf("ref %v locked: %w", wOpts.Ref, errdefs.ErrUnavailable)
+	}
 
 	now := time.Now()
-	return &memoryWriter{
-		store:    m.store,
+	w := &memoryWriter{
+		ms:       m,
 		buffer:   bytes.NewBuffer(nil),
 		desc:     desc,
 		digester: digest.Canonical.Digester(),
 		status: ccontent.Status{
-			Ref:       name,
+			Ref:       wOpts.Ref,
 			Total:     desc.Size,
+			Expected:  desc.Digest,
 			StartedAt: now,
 			UpdatedAt: now,
 		},
-	}, nil
+	}
+	m.ingests[wOpts.Ref] = w
+	return w, nil
 }
 
-// Get returns the content for a specific descriptor
+// Get returns the content for a specific descriptor; the content must not be modified
 func (m *MemoryStore) Get(desc ocispec.Descriptor) (ocispec.Descriptor, []byte, bool) {
-	rc, err := m.store.Fetch(context.Background(), desc)
-	if err != nil {
-		return desc, nil, false
+	content, ok := m.content(desc)
+	return desc, content, ok
+}
+
+// Set sets the content for a specific descriptor, returning an error if the content
+// does not match the descriptor; the store keeps the slice without copying it
+func (m *MemoryStore) Set(desc ocispec.Descriptor, content []byte) error {
+	return m.add(desc, content)
+}
+
+// SetDescriptor records a descriptor for content which is not retrieved (such as a
+// layer), so that its details are available for pushing references to it
+func (m *MemoryStore) SetDescriptor(desc ocispec.Descriptor) {
+	m.l.Lock()
+	if _, ok := m.blobs[desc.Digest]; !ok {
+		m.descriptors[desc.Digest] = desc
 	}
-	defer rc.Close() //nolint:errcheck
+	m.l.Unlock()
+}
 
-	var buf bytes.Buffer
-	if _, err := io.Copy(&buf, rc); err != nil {
-		return desc, nil, false
+// Descriptor returns the descriptor recorded for a digest with SetDescriptor
+func (m *MemoryStore) Descriptor(d digest.Digest) (ocispec.Descriptor, bool) {
+	m.l.RLock()
+	defer m.l.RUnlock()
+	desc, ok := m.descriptors[d]
+	return desc, ok
+}
+
+func (m *MemoryStore) content(desc ocispec.Descriptor) ([]byte, bool) {
+	m.l.RLock()
+	b, ok := m.blobs[desc.Digest]
+	m.l.RUnlock()
+	if ok {
+		return b.content, true
 	}
-	return desc, buf.Bytes(), true
+	if m.cache == nil {
+		return nil, false
+	}
+	content, ok := m.cache.Get(desc.Digest)
+	if !ok {
+		return nil, false
+	}
+	m.insert(desc, content)
+	return content, true
+}
+
+// add stores content which matches its descriptor, adding it to the cache as well
+func (m *MemoryStore) add(desc ocispec.Descriptor, content []byte) error {
+	if err := verifyContent(desc, content); err != nil {
+		return err
+	}
+	if m.insert(desc, content) && m.cache != nil {
+		if err := m.cache.Put(desc, content); err != nil {
+			// the cache is an optimization only
+			logrus.Warnf("unable to add %s to the content cache: %v", desc.Digest, err)
+		}
+	}
+	return nil
 }
 
-// Set sets the content for a specific descriptor
-func (m *MemoryStore) Set(desc ocispec.Descriptor, content []byte) {
+// verifyContent checks that content matches the size and digest of its descriptor
+func verifyContent(desc ocispec.Descriptor, content []byte) error {
+	if desc.Size != int64(len(content)) {
+		return fmt.Errorf("content size %d does not match descriptor size %d: %w", len(content), desc.Size, errdefs.ErrFailedPrecondition)
+	}
+	if err := desc.Digest.Validate(); err != nil {
+		return fmt.Errorf("invalid descriptor digest: %v: %w", err, errdefs.ErrInvalidArgument)
+	}
+	if dgst := desc.Digest.Algorithm().FromBytes(content); dgst != desc.Digest {
+		return fmt.Errorf("content digest %s does not match descriptor digest %s: %w", dgst, desc.Digest, errdefs.ErrFailedPrecondition)
+	}
+	return nil
+}
+
+// insert records verified content, returning false if it was already in the store
+func (m *MemoryStore) insert(desc ocispec.Descriptor, content []byte) bool {
+	m.l.Lock()
+	defer m.l.Unlock()
 	if name, ok := resolveName(desc); ok {
 		m.nameMap[name] = desc
 	}
-	_ = m.store.Push(context.Background(), desc, bytes.NewReader(content))
+	now := time.Now()
+	if b, ok := m.blobs[desc.Digest]; ok {
+		b.updatedAt = now
+		return false
+	}
+	m.blobs[desc.Digest] = &blob{
+		content:   content,
+		createdAt: now,
+		updatedAt: now,
+	}
+	delete(m.descriptors, desc.Digest)
+	return true
 }
 
 // GetByName retrieves a descriptor based on the associated name
 func (m *MemoryStore) GetByName(name string) (desc ocispec.Descriptor, content []byte, found bool) {
+	m.l.RLock()
 	desc, found = m.nameMap[name]
+	m.l.RUnlock()
 	if !found {
 		return desc, nil, false
 	}
 	return m.Get(desc)
 }
 
-// Abort is not implemented or needed in this context
+// Abort cancels the ingest of content by a writer for the ref
 func (m *MemoryStore) Abort(ctx context.Context, ref string) error {
+	m.l.Lock()
+	defer m.l.Unlock()
+	w, ok := m.ingests[ref]
+	if !ok {
+		return fmt.Errorf("ingest ref %q: %w", ref, errdefs.ErrNotFound)
+	}
+	delete(m.ingests, ref)
+	w.buffer = nil
 	return nil
 }
 
-// ListStatuses is not implemented or needed in this context
-func (m *MemoryStore) ListStatuses(ctx context.Context, filters ...string) ([]ccontent.Status, error) {
-	return []ccontent.Status{}, nil
+// ListStatuses returns the status of each ongoing ingest which matches any of
+// the filters (on "ref" or "expected"), ordered by ref
+func (m *MemoryStore) ListStatuses(ctx context.Context, fs ...string) ([]ccontent.Status, error) {
+	filter, err := filters.ParseAll(fs...)
+	if err != nil {
+		return nil, fmt.Errorf("invalid status filter: %v: %w", err, errdefs.ErrInvalidArgument)
+	}
+	m.l.RLock()
+	writers := make([]*memoryWriter, 0, len(m.ingests))
+	for _, w := range m.ingests {
+		writers = append(writers, w)
+	}
+	m.l.RUnlock()
+
+	statuses := []ccontent.Status{}
+	for _, w := range writers {
+		status, _ := w.Status()
+		if filter.Match(adaptStatus(status)) {
+			statuses = append(statuses, status)
+		}
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Ref < statuses[j].Ref })
+	return statuses, nil
 }
 
-// Status is not implemented or needed in this context
+// Status returns the status of the ongoing ingest for the ref
 func (m *MemoryStore) Status(ctx context.Context, ref string) (ccontent.Status, error) {
-	return ccontent.Status{}, nil
+	m.l.RLock()
+	w, ok := m.ingests[ref]
+	m.l.RUnlock()
+	if !ok {
+		return ccontent.Status{}, fmt.Errorf("status for ref %q: %w", ref, errdefs.ErrNotFound)
+	}
+	return w.Status()
+}
+
+// removeIngest stops tracking the ingest of the writer, if it is still the one for its ref
+func (m *MemoryStore) removeIngest(w *memoryWriter) {
+	m.l.Lock()
+	if m.ingests[w.status.Ref] == w {
+		delete(m.ingests, w.status.Ref)
+	}
+	m.l.Unlock()
+}
+
+func adaptInfo(info ccontent.Info) filters.Adaptor {
+	return filters.AdapterFunc(func(fieldpath []string) (string, bool) {
+		if len(fieldpath) == 0 {
+			return "", false
+		}
+		switch fieldpath[0] {
+		case "digest":
+			return info.Digest.String(), true
+		case "size":
+			return strconv.FormatInt(info.Size, 10), true
+		case "labels":
+			value, ok := info.Labels[strings.Join(fieldpath[1:], ".")]
+			return value, ok
+		}
+		return "", false
+	})
+}
+
+func adaptStatus(status ccontent.Status) filters.Adaptor {
+	return filters.AdapterFunc(func(fieldpath []string) (string, bool) {
+		if len(fieldpath) == 0 {
+			return "", false
+		}
+		switch fieldpath[0] {
+		case "ref":
+			return status.Ref, true
+		case "expected":
+			return status.Expected.String(), status.Expected != ""
+		}
+		return "", false
+	})
+}
+
+func copyLabels(labels map[string]string) map[string]string {
+	if labels == nil {
+		return nil
+	}
+	c := make(map[string]string, len(labels))
+	for k, v := range labels {
+		c[k] = v
+	}
+	return c
 }
 
 // the rest of this file contains the original "memoryWriter" implementation
@@ -228,14 +554,18 @@ func (nopCloser) Close() error {
 }
 
 type memoryWriter struct {
-	store    *memory.Store
+	ms       *MemoryStore
 	buffer   *bytes.Buffer
 	desc     ocispec.Descriptor
 	digester digest.Digester
-	status   ccontent.Status
+	// l guards status, which is read by the store while the writer is in use
+	l      sync.Mutex
+	status ccontent.Status
 }
 
 func (w *memoryWriter) Status() (ccontent.Status, error) {
+	w.l.Lock()
+	defer w.l.Unlock()
 	return w.status, nil
 }
 
@@ -248,10 +578,15 @@ func (w *memoryWriter) Digest() digest.Digest {
 
 // Write p to the transaction.
 func (w *memoryWriter) Write(p []byte) (n int, err error) {
+	if w.buffer == nil {
+		return 0, fmt.Errorf("cannot write on closed writer: %w", errdefs.ErrFailedPrecondition)
+	}
 	n, err = w.buffer.Write(p)
 	w.digester.Hash().Write(p[:n])
+	w.l.Lock()
 	w.status.Offset += int64(len(p))
 	w.status.UpdatedAt = time.Now()
+	w.l.Unlock()
 	return n, err
 }
 
@@ -268,20 +603,35 @@ func (w *memoryWriter) Commit(ctx context.Context, size int64, expected digest.D
 	}
 	content := w.buffer.Bytes()
 	w.buffer = nil
+	defer w.ms.removeIngest(w)
 
 	if size > 0 && size != int64(len(content)) {
 		return fmt.Errorf("unexpected commit size %d, expected %d: %w", len(content), size, errdefs.ErrFailedPrecondition)
 	}
-	if dgst := w.digester.Digest(); expected != "" && expected != dgst {
+	dgst := w.digester.Digest()
+	if expected != "" && expected != dgst {
 		return fmt.Errorf("unexpected commit digest %s, expected %s: %w", dgst, expected, errdefs.ErrFailedPrecondition)
 	}
 
-	_ = w.store.Push(context.Background(), w.desc, bytes.NewReader(content))
+	desc := w.desc
+	desc.Digest = dgst
+	desc.Size = int64(len(content))
+	if err := w.ms.add(desc, content); err != nil {
+		return fmt.Errorf("failed to store content %s: %w", desc.Digest, err)
+	}
+	if len(base.Labels) > 0 {
+		if _, err := w.ms.update(desc.Digest, base.Labels); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+// Close releases the writer; as content is only held in memory an ingest which
+// has not been committed cannot be resumed, so it is removed like an abort
 func (w *memoryWriter) Close() error {
 	w.buffer = nil
+	w.ms.removeIngest(w)
 	return nil
 }
 
@@ -289,7 +639,9 @@ func (w *memoryWriter) Truncate(size int64) error {
 	if size != 0 {
 		return errdefs.ErrInvalidArgument
 	}
+	w.l.Lock()
 	w.status.Offset = 0
+	w.l.Unlock()
 	w.digester.Hash().Reset()
 	w.buffer.Truncate(0)
 	return nil
@@ -0,0 +1,290 @@
+package store
+
+import (
+	"context"
+	"testing"
+
+	ccontent "github.com/containerd/containerd/v2/core/content"
+	"github.com/containerd/errdefs"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+func descriptor(mediaType string, content []byte) ocispec.Descriptor {
+	return ocispec.Descriptor{
+		MediaType: mediaType,
+		Digest:    digest.FromBytes(content),
+		Size:      int64(len(content)),
+	}
+}
+
+func TestWalk(t *testing.T) {
+	ctx := context.Background()
+	ms := NewMemoryStore()
+	var descs []ocispec.Descriptor
+	for _, content := range []string{"one", "two", "three"} {
+		desc := descriptor(ocispec.MediaTypeImageConfig, []byte(content))
+		ms.Set(desc, []byte(content))
+		descs = append(descs, desc)
+	}
+	if _, err := ms.Update(ctx, ccontent.Info{Digest: descs[1].Digest, Labels: map[string]string{"a.b/c": "d"}}); err != nil {
+		t.Fatal(err)
+	}
+	// labels without content are not walked
+	if _, err := ms.Update(ctx, ccontent.Info{Digest: digest.FromString("layer"), Labels: map[string]string{"a.b/c": "d"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		filters []string
+		want    []ocispec.Descriptor
+	}{
+		{want: descs},
+		{filters: []string{`labels."a.b/c"==d`}, want: descs[1:2]},
+		{filters: []string{"digest==" + descs[0].Digest.String(), "digest==" + descs[2].Digest.String()}, want: []ocispec.Descriptor{descs[0], descs[2]}},
+		{filters: []string{"size==3"}, want: descs[:2]},
+		{filters: []string{`labels."x"`}},
+	}
+	for _, tt := range tests {
+		var got []ccontent.Info
+		if err := ms.Walk(ctx, func(info ccontent.Info) error {
+			got = append(got, info)
+			return nil
+		}, tt.filters...); err != nil {
+			t.Fatalf("walk with %v: %v", tt.filters, err)
+		}
+		want := map[digest.Digest]int64{}
+		for _, desc := range tt.want {
+			want[desc.Digest] = desc.Size
+		}
+		if len(got) != len(want) {
+			t.Fatalf("walk with %v: got %d entries, want %d", tt.filters, len(got), len(want))
+		}
+		for i, info := range got {
+			if size, ok := want[info.Digest]; !ok || size != info.Size {
+				t.Errorf("walk with %v: unexpected entry %v (size %d)", tt.filters, info.Digest, info.Size)
+			}
+			if i > 0 && got[i-1].Digest >= info.Digest {
+				t.Errorf("walk with %v: entries not in digest order", tt.filters)
+			}
+		}
+	}
+	if err := ms.Walk(ctx, func(ccontent.Info) error { return nil }, "labels.=="); !errdefs.IsInvalidArgument(err) {
+		t.Errorf("walk with an invalid filter: got %v, want an invalid argument error", err)
+	}
+}
+
+func TestDelete(t *testing.T) {
+	ctx := context.Background()
+	ms := NewMemoryStore()
+	indexContent := []byte(`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.index.v1+json","manifests":[]}`)
+	index := descriptor(ocispec.MediaTypeImageIndex, indexContent)
+	index.Annotations = map[string]string{ocispec.AnnotationRefName: "example.com/index:1"}
+	config := descriptor(ocispec.MediaTypeImageConfig, []byte("config"))
+	ms.Set(index, indexContent)
+	ms.Set(config, []byte("config"))
+	if _, err := ms.Update(ctx, ccontent.Info{Digest: index.Digest, Labels: map[string]string{"a": "b"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ms.Delete(ctx, index.Digest); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, found := ms.Get(index); found {
+		t.Error("deleted content is still in the store")
+	}
+	if _, _, found := ms.GetByName("example.com/index:1"); found {
+		t.Error("name of deleted content is still in the store")
+	}
+	if _, err := ms.Info(ctx, index.Digest); !errdefs.IsNotFound(err) {
+		t.Errorf("info of deleted content: got %v, want a not found error", err)
+	}
+	if _, content, found := ms.Get(config); !found || string(content) != "config" {
+		t.Errorf("content which was not deleted: got %q (found %v)", content, found)
+	}
+	if err := ms.Delete(ctx, index.Digest); !errdefs.IsNotFound(err) {
+		t.Errorf("delete of missing content: got %v, want a not found error", err)
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	ctx := context.Background()
+	ms := NewMemoryStore()
+	d := digest.FromString("layer")
+
+	if _, err := ms.Info(ctx, d); !errdefs.IsNotFound(err) {
+		t.Errorf("info of unknown digest: got %v, want a not found error", err)
+	}
+	if _, err := ms.Update(ctx, ccontent.Info{Digest: d, Labels: map[string]string{"a": "1", "b": "2"}}); err != nil {
+		t.Fatal(err)
+	}
+	info, err := ms.Update(ctx, ccontent.Info{Digest: d, Labels: map[string]string{"a": "", "b": "3", "c": "4"}}, "labels.a", "labels.b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(info.Labels) != 1 || info.Labels["b"] != "3" {
+		t.Errorf("labels after field path update: got %v, want map[b:3]", info.Labels)
+	}
+	if info, err := ms.Info(ctx, d); err != nil || len(info.Labels) != 1 {
+		t.Errorf("info of digest with labels only: got %v (%v)", info, err)
+	}
+
+	if _, err := ms.Update(ctx, ccontent.Info{Digest: d}, "size"); !errdefs.IsInvalidArgument(err) {
+		t.Errorf("update of unsupported field path: got %v, want an invalid argument error", err)
+	}
+	if _, err := ms.Update(ctx, ccontent.Info{Labels: map[string]string{"a": "1"}}); !errdefs.IsInvalidArgument(err) {
+		t.Errorf("update without digest: got %v, want an invalid argument error", err)
+	}
+}
+
+func TestWriter(t *testing.T) {
+	ctx := context.Background()
+	ms := NewMemoryStore()
+	content := []byte(`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json"}`)
+	desc := descriptor(ocispec.MediaTypeImageManifest, content)
+
+	if _, err := ms.Writer(ctx, ccontent.WithDescriptor(desc)); !errdefs.IsInvalidArgument(err) {
+		t.Errorf("writer without ref: got %v, want an invalid argument error", err)
+	}
+	w, err := ms.Writer(ctx, ccontent.WithRef("manifest"), ccontent.WithDescriptor(desc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ms.Writer(ctx, ccontent.WithRef("manifest"), ccontent.WithDescriptor(desc)); !errdefs.IsUnavailable(err) {
+		t.Errorf("second writer for ref: got %v, want an unavailable error", err)
+	}
+	if _, err := w.Write(content[:8]); err != nil {
+		t.Fatal(err)
+	}
+	status, err := ms.Status(ctx, "manifest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Offset != 8 || status.Total != desc.Size || status.Expected != desc.Digest {
+		t.Errorf("ingest status: got %+v", status)
+	}
+
+	other, err := ms.Writer(ctx, ccontent.WithRef("config"), ccontent.WithDescriptor(descriptor(ocispec.MediaTypeImageConfig, []byte("{}"))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	statuses, err := ms.ListStatuses(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(statuses) != 2 || statuses[0].Ref != "config" || statuses[1].Ref != "manifest" {
+		t.Errorf("ingest statuses: got %+v", statuses)
+	}
+	if statuses, err := ms.ListStatuses(ctx, "ref==manifest"); err != nil || len(statuses) != 1 {
+		t.Errorf("filtered ingest statuses: got %+v (%v)", statuses, err)
+	}
+	if err := ms.Abort(ctx, "config"); err != nil {
+		t.Fatal(err)
+	}
+	if err := other.Commit(ctx, 2, ""); !errdefs.IsFailedPrecondition(err) {
+		t.Errorf("commit of aborted ingest: got %v, want a failed precondition error", err)
+	}
+	if err := ms.Abort(ctx, "config"); !errdefs.IsNotFound(err) {
+		t.Errorf("abort of missing ingest: got %v, want a not found error", err)
+	}
+
+	if _, err := w.Write(content[8:]); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Commit(ctx, desc.Size, desc.Digest, ccontent.WithLabels(map[string]string{"a": "b"})); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ms.Status(ctx, "manifest"); !errdefs.IsNotFound(err) {
+		t.Errorf("status of committed ingest: got %v, want a not found error", err)
+	}
+	if _, got, found := ms.Get(desc); !found || string(got) != string(content) {
+		t.Errorf("committed content: got %q (found %v)", got, found)
+	}
+	if info, err := ms.Info(ctx, desc.Digest); err != nil || info.Size != desc.Size || info.Labels["a"] != "b" {
+		t.Errorf("committed content info: got %+v (%v)", info, err)
+	}
+	if _, err := ms.Writer(ctx, ccontent.WithRef("manifest"), ccontent.WithDescriptor(desc)); !errdefs.IsAlreadyExists(err) {
+		t.Errorf("writer for existing content: got %v, want an already exists error", err)
+	}
+
+	w, err = ms.Writer(ctx, ccontent.WithRef("bad"), ccontent.WithDescriptor(descriptor(ocispec.MediaTypeImageConfig, []byte("config"))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte("other content")); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Commit(ctx, 0, digest.FromString("config")); !errdefs.IsFailedPrecondition(err) {
+		t.Errorf("commit with unexpected digest: got %v, want a failed precondition error", err)
+	}
+	if _, err := ms.Status(ctx, "bad"); !errdefs.IsNotFound(err) {
+		t.Errorf("status of failed ingest: got %v, want a not found error", err)
+	}
+}
+
+func TestDescriptorOnly(t *testing.T) {
+	ctx := context.Background()
+	ms := NewMemoryStore()
+	layer := ocispec.Descriptor{
+		MediaType: ocispec.MediaTypeImageLayerGzip,
+		Digest:    digest.FromString("layer"),
+		Size:      1024,
+	}
+	ms.SetDescriptor(layer)
+
+	if desc, ok := ms.Descriptor(layer.Digest); !ok || desc.Size != layer.Size {
+		t.Errorf("descriptor-only entry: got %v (found %v)", desc, ok)
+	}
+	if info, err := ms.Info(ctx, layer.Digest); err != nil || info.Size != layer.Size {
+		t.Errorf("info of descriptor-only entry: got %+v (%v)", info, err)
+	}
+	if _, _, found := ms.Get(layer); found {
+		t.Error("descriptor-only entry has content")
+	}
+	if _, err := ms.ReaderAt(ctx, layer); !errdefs.IsNotFound(err) {
+		t.Errorf("reader for descriptor-only entry: got %v, want a not found error", err)
+	}
+	if err := ms.Walk(ctx, func(info ccontent.Info) error {
+		t.Errorf("descriptor-only entry %v walked as content", info.Digest)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ms.Delete(ctx, layer.Digest); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := ms.Descriptor(layer.Digest); ok {
+		t.Error("deleted descriptor-only entry is still in the store")
+	}
+}
+
+func TestSetContent(t *testing.T) {
+	ctx := context.Background()
+	ms := NewMemoryStore()
+	content := []byte(`{"architecture":"amd64","os":"linux"}`)
+	desc := descriptor(ocispec.MediaTypeImageConfig, content)
+	if err := ms.Set(desc, content); err != nil {
+		t.Fatal(err)
+	}
+
+	// content is served from the slice it was stored with
+	if _, got, found := ms.Get(desc); !found || &got[0] != &content[0] {
+		t.Errorf("stored content was copied or not found (found %v)", found)
+	}
+	ra, err := ms.ReaderAt(ctx, desc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ra.Size() != desc.Size {
+		t.Errorf("reader size: got %d, want %d", ra.Size(), desc.Size)
+	}
+
+	// content which does not match its descriptor is an error and is not stored
+	bad := descriptor(ocispec.MediaTypeImageLayerGzip, []byte("layer"))
+	if err := ms.Set(bad, []byte{}); !errdefs.IsFailedPrecondition(err) {
+		t.Errorf("expected a failed precondition error for mismatched content, got %v", err)
+	}
+	if _, _, found := ms.Get(bad); found {
+		t.Error("content not matching its descriptor was stored")
+	}
+}
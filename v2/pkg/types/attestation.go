@@ -0,0 +1,34 @@
+package types
+
+import "encoding/json"
+
+const (
+	// MediaTypeInToto is the media type of an attestation manifest layer holding an in-toto statement
+	MediaTypeInToto = "application/vnd.in-toto+json"
+	// AnnotationInTotoPredicateType is the layer annotation carrying the predicate type of the statement
+	AnnotationInTotoPredicateType = "in-toto.io/predicate-type"
+
+	// PredicateSLSAProvenanceV02 is the predicate type of SLSA v0.2 build provenance
+	PredicateSLSAProvenanceV02 = "https://slsa.dev/provenance/v0.2"
+	// PredicateSLSAProvenanceV1 is the predicate type of SLSA v1 build provenance
+	PredicateSLSAProvenanceV1 = "https://slsa.dev/provenance/v1"
+	// PredicateSPDX is the predicate type of an SPDX software bill of materials
+	PredicateSPDX = "https://spdx.dev/Document"
+	// PredicateCycloneDX is the predicate type of a CycloneDX software bill of materials
+	PredicateCycloneDX = "https://cyclonedx.org/bom"
+)
+
+// InTotoStatement is an in-toto attestation statement as stored in the layers
+// of an attestation manifest; the predicate is decoded based on its type
+type InTotoStatement struct {
+	Type          string          `json:"_type"`
+	PredicateType string          `json:"predicateType"`
+	Subject       []InTotoSubject `json:"subject"`
+	Predicate     json.RawMessage `json:"predicate"`
+}
+
+// InTotoSubject is an artifact (name and digests) an in-toto statement applies to
+type InTotoSubject struct {
+	Name   string            `json:"name"`
+	Digest map[string]string `json:"digest"`
+}
@@ -11,8 +11,24 @@ const (
 	MediaTypeDockerSchema2ManifestList = "application/vnd.docker.distribution.manifest.list.v2+json"
 	// MediaTypeDockerTarGzipLayer is the Docker schema media type for a tar+gzip filesystem layer
 	MediaTypeDockerTarGzipLayer = "application/vnd.docker.image.rootfs.diff.tar.gzip"
+	// MediaTypeDockerSchema2Config is the Docker v2.2 schema media type for an image config object
+	MediaTypeDockerSchema2Config = "application/vnd.docker.container.image.v1+json"
 )
 
+// IsArtifactManifest returns true if the manifest describes an OCI artifact (such as a
+// Helm chart or WASM module) rather than a container image; that is, it declares an
+// artifactType or its config is not a container image config
+func IsArtifactManifest(man ocispec.Manifest) bool {
+	if man.ArtifactType != "" {
+		return true
+	}
+	switch man.Config.MediaType {
+	case ocispec.MediaTypeImageConfig, MediaTypeDockerSchema2Config:
+		return false
+	}
+	return true
+}
+
 // Image struct handles Windows support extensions to OCI spec
 type Image struct {
 	ocispec.Image
@@ -0,0 +1,51 @@
+package types
+
+import (
+	"testing"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+func TestIsArtifactManifest(t *testing.T) {
+	var tests = []struct {
+		name     string
+		manifest ocispec.Manifest
+		want     bool
+	}{
+		{
+			name:     "oci image",
+			manifest: ocispec.Manifest{Config: ocispec.Descriptor{MediaType: ocispec.MediaTypeImageConfig}},
+			want:     false,
+		},
+		{
+			name:     "docker image",
+			manifest: ocispec.Manifest{Config: ocispec.Descriptor{MediaType: MediaTypeDockerSchema2Config}},
+			want:     false,
+		},
+		{
+			name:     "artifact type",
+			manifest: ocispec.Manifest{ArtifactType: "application/vnd.example.sbom", Config: ocispec.Descriptor{MediaType: ocispec.MediaTypeImageConfig}},
+			want:     true,
+		},
+		{
+			name:     "empty config",
+			manifest: ocispec.Manifest{ArtifactType: "application/wasm", Config: ocispec.DescriptorEmptyJSON},
+			want:     true,
+		},
+		{
+			name:     "empty config without artifact type",
+			manifest: ocispec.Manifest{Config: ocispec.DescriptorEmptyJSON},
+			want:     true,
+		},
+		{
+			name:     "custom config",
+			manifest: ocispec.Manifest{Config: ocispec.Descriptor{MediaType: "application/vnd.cncf.helm.config.v1+json"}},
+			want:     true,
+		},
+	}
+	for _, tt := range tests {
+		if got := IsArtifactManifest(tt.manifest); got != tt.want {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, got)
+		}
+	}
+}
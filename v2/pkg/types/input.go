@@ -1,19 +1,124 @@
 package types
 
-import ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+import (
+	"fmt"
+	"strings"
+
+	"github.com/containerd/platforms"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	yaml "gopkg.in/yaml.v3"
+)
 
 // YAMLInput contains the parsed yaml fields from the push
-// command of manifest-tool
+// command of manifest-tool. Besides the listed Manifests, an
+// entry is added for each of the Platforms (except those matching
+// an Exclude platform) with its image named by the Template.
 type YAMLInput struct {
 	Image       string
 	Tags        []string
 	Manifests   []ManifestEntry
 	Annotations map[string]string
+	Template    string
+	Platforms   []PlatformEntry
+	Exclude     []string
 }
 
 // ManifestEntry contains an image reference and it's corresponding OCI
-// platform definition (OS/Arch/Variant)
+// platform definition (OS/Arch/Variant). If the image reference is an
+// index/manifest list, Nested determines whether it is kept as a single
+// nested index entry instead of being flattened into its member manifests.
+// Convert allows a legacy Docker schema 1 image to be converted into a
+// manifest of the target type so that it can be included. Annotations are
+// added to the descriptor of each manifest of the entry in the index. For an
+// image reference which is an index/manifest list, Platforms (of the form
+// "os/arch[/variant]") selects the manifests to include instead of all of them.
+// OCI rewrites the Docker v2 manifests of the image to OCI media types in the
+// target repository, for an index of only OCI manifests.
 type ManifestEntry struct {
-	Image    string
-	Platform ocispec.Platform
+	Image       string
+	Platform    ocispec.Platform
+	Nested      bool
+	Convert     bool
+	Annotations map[string]string
+	Platforms   []string
+	OCI         bool
+}
+
+// PlatformEntry is a platform of the template of a YAMLInput. In YAML it is
+// either an "os/arch[/variant]" string, or a mapping with that string as its
+// "platform", an "image" which overrides the template for the platform and
+// the "annotations" of its manifest entry.
+type PlatformEntry struct {
+	Platform    ocispec.Platform
+	Image       string
+	Annotations map[string]string
+}
+
+// UnmarshalYAML decodes either form of a platform entry
+func (p *PlatformEntry) UnmarshalYAML(value *yaml.Node) error {
+	var platform string
+	switch value.Kind {
+	case yaml.ScalarNode:
+		platform = value.Value
+	case yaml.MappingNode:
+		var entry struct {
+			Platform    string
+			Image       string
+			Annotations map[string]string
+		}
+		if err := value.Decode(&entry); err != nil {
+			return err
+		}
+		platform, p.Image, p.Annotations = entry.Platform, entry.Image, entry.Annotations
+	default:
+		return &SpecError{Line: value.Line, Column: value.Column, Err: fmt.Errorf("expected a platform string or a mapping with platform and image keys")}
+	}
+	parsed, err := ParsePlatform(platform)
+	if err != nil {
+		return &SpecError{Line: value.Line, Column: value.Column, Err: err}
+	}
+	p.Platform = parsed
+	return nil
+}
+
+// ParsePlatform parses a platform of the form "os/arch" or "os/arch/variant"
+func ParsePlatform(platform string) (ocispec.Platform, error) {
+	parts := strings.Split(platform, "/")
+	if (len(parts) != 2 && len(parts) != 3) || parts[0] == "" || parts[1] == "" {
+		return ocispec.Platform{}, fmt.Errorf("invalid platform %q: expected the form 'os/arch' or 'os/arch/variant'", platform)
+	}
+	p := ocispec.Platform{
+		OS:           parts[0],
+		Architecture: parts[1],
+	}
+	if len(parts) == 3 {
+		p.Variant = parts[2]
+	}
+	return p, nil
+}
+
+// PlatformMatches returns true if the platform matches the selector: the same os and
+// architecture, and the same variant unless the selector does not have a variant. Variants
+// are compared under the containerd platform rules, so that the default variant of an
+// architecture (such as v8 of arm64) also matches a platform without a variant.
+func PlatformMatches(selector, platform ocispec.Platform) bool {
+	if selector.OS != platform.OS || selector.Architecture != platform.Architecture {
+		return false
+	}
+	return selector.Variant == "" || platforms.Normalize(selector).Variant == platforms.Normalize(platform).Variant
+}
+
+// SpecError is an error at a position (line and column) of a YAML spec
+type SpecError struct {
+	Line   int
+	Column int
+	Err    error
+}
+
+func (e *SpecError) Error() string {
+	return fmt.Sprintf("line %d, column %d: %v", e.Line, e.Column, e.Err)
+}
+
+func (e *SpecError) Unwrap() error {
+	return e.Err
 }
@@ -1,6 +1,8 @@
 package types
 
 import (
+	"time"
+
 	"github.com/containerd/containerd/v2/core/remotes"
 	"github.com/docker/distribution/reference"
 	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
@@ -16,8 +18,72 @@ const (
 	OCI ManifestType = iota
 	// Docker is used for the "manifestList" type
 	Docker
+	// Auto selects the "index" type if OCI-only features (such as annotations or nested
+	// indexes) are used, and the "manifestList" type otherwise
+	Auto
+)
+
+// String returns the name of the manifest type, as given to the --type flag
+func (t ManifestType) String() string {
+	switch t {
+	case OCI:
+		return "oci"
+	case Docker:
+		return "docker"
+	case Auto:
+		return "auto"
+	}
+	return "unknown"
+}
+
+// PlatformPolicy specifies how a manifest list/index push handles a manifest entry
+// whose platform does not match the os/architecture/variant of its image config.
+type PlatformPolicy int
+
+const (
+	// PlatformWarn logs a warning and keeps the platform of the manifest entry
+	PlatformWarn PlatformPolicy = iota
+	// PlatformError fails the push
+	PlatformError
+	// PlatformOverride logs a warning and uses the platform of the image config
+	PlatformOverride
 )
 
+// Reproducible specifies that a manifest list/index is built reproducibly: the same
+// input and member image digests always give the same digest.
+type Reproducible struct {
+	// PlatformOrder lists platforms whose manifests come first, in this order, before
+	// the others; a platform without a variant matches all of its variants
+	PlatformOrder []ocispec.Platform
+	// Created is recorded as the "org.opencontainers.image.created" annotation of an
+	// OCI index, unless it is zero or the index has the annotation already
+	Created time.Time
+}
+
+// LabelAnnotations specifies the image config labels of the member images to promote to
+// annotations of an OCI index: labels with the same value in every member image.
+type LabelAnnotations struct {
+	// Keys lists the labels to promote; all labels are considered if it is empty
+	Keys []string
+}
+
+// PushOptions holds the settings of a manifest list/index push; the zero value pushes
+// over HTTPS, fails on missing member images and warns about platform mismatches.
+type PushOptions struct {
+	// IgnoreMissing skips member images which cannot be found instead of failing the push
+	IgnoreMissing bool
+	// Insecure allows registry connections without TLS certificate verification
+	Insecure bool
+	// PlainHTTP connects to the registry over HTTP
+	PlainHTTP bool
+	// PlatformPolicy handles manifest entries whose platform does not match their image config
+	PlatformPolicy PlatformPolicy
+	// Reproducible, if set, sorts the manifests and encodes the list canonically
+	Reproducible *Reproducible
+	// Labels, if set, adds labels of the member images to the annotations of an OCI index
+	Labels *LabelAnnotations
+}
+
 // ManifestList represents the information necessary to assemble and
 // push the right data to a registry to form a manifestlist or OCI index
 // entry.
@@ -28,6 +94,8 @@ type ManifestList struct {
 	Resolver    remotes.Resolver
 	Manifests   []Manifest
 	Annotations map[string]string
+	// Reproducible, if set, builds the manifest list/index reproducibly
+	Reproducible *Reproducible
 }
 
 // Manifest is an ocispec.Descriptor of media type manifest (OCI or Docker)
@@ -0,0 +1,62 @@
+package types
+
+import (
+	"time"
+
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+const (
+	// MediaTypeDockerSchema1Manifest is the legacy Docker v2.1 (schema 1) media type for a signed manifest object
+	MediaTypeDockerSchema1Manifest = "application/vnd.docker.distribution.manifest.v1+prettyjws"
+	// MediaTypeDockerSchema1UnsignedManifest is the legacy Docker v2.1 (schema 1) media type for an unsigned manifest object
+	MediaTypeDockerSchema1UnsignedManifest = "application/vnd.docker.distribution.manifest.v1+json"
+	// EmptySchema1Layer is the digest of the empty gzipped tar layer schema 1 manifests use for
+	// history entries which did not change the filesystem
+	EmptySchema1Layer = digest.Digest("sha256:a3ed95caeb02ffe68cdd9fd84406680ae93d633cb16422d00e8a7c22955b46d4")
+)
+
+// IsSchema1 returns true if the media type is one of the Docker schema 1 manifest types
+func IsSchema1(mediaType string) bool {
+	return mediaType == MediaTypeDockerSchema1Manifest || mediaType == MediaTypeDockerSchema1UnsignedManifest
+}
+
+// Schema1Manifest is a legacy Docker schema 1 image manifest. The layers and history
+// entries are ordered from the top (most recent) layer down to the base layer.
+type Schema1Manifest struct {
+	SchemaVersion int              `json:"schemaVersion"`
+	Name          string           `json:"name"`
+	Tag           string           `json:"tag"`
+	Architecture  string           `json:"architecture"`
+	FSLayers      []Schema1FSLayer `json:"fsLayers"`
+	History       []Schema1History `json:"history"`
+}
+
+// Schema1FSLayer is the digest of a (gzipped tar) layer of a schema 1 manifest
+type Schema1FSLayer struct {
+	BlobSum digest.Digest `json:"blobSum"`
+}
+
+// Schema1History holds the v1 image JSON for the matching layer of a schema 1 manifest
+type Schema1History struct {
+	V1Compatibility string `json:"v1Compatibility"`
+}
+
+// Schema1Image contains the fields of the v1 image JSON of a schema 1 history entry which
+// are needed to display the image and to synthesize an image config from it
+type Schema1Image struct {
+	ID              string               `json:"id"`
+	Parent          string               `json:"parent,omitempty"`
+	Created         *time.Time           `json:"created,omitempty"`
+	Author          string               `json:"author,omitempty"`
+	Comment         string               `json:"comment,omitempty"`
+	OS              string               `json:"os,omitempty"`
+	Architecture    string               `json:"architecture,omitempty"`
+	Variant         string               `json:"variant,omitempty"`
+	Config          *ocispec.ImageConfig `json:"config,omitempty"`
+	ContainerConfig struct {
+		Cmd []string `json:"Cmd,omitempty"`
+	} `json:"container_config,omitempty"`
+	ThrowAway bool `json:"throwaway,omitempty"`
+}
@@ -0,0 +1,73 @@
+package util
+
+import (
+	"fmt"
+	"net/url"
+	"os/exec"
+	"strings"
+	"time"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// GitAnnotations returns the org.opencontainers.image source, revision and created
+// annotations of the HEAD commit of a local git checkout: the URL of its origin remote,
+// the commit hash and the commit date. The source is left out if there is no origin.
+func GitAnnotations(dir string) (map[string]string, error) {
+	revision, err := git(dir, "rev-parse", "HEAD")
+	if err != nil {
+		return nil, err
+	}
+	committed, err := git(dir, "show", "-s", "--format=%cI", "HEAD")
+	if err != nil {
+		return nil, err
+	}
+	created, err := time.Parse(time.RFC3339, committed)
+	if err != nil {
+		return nil, fmt.Errorf("unexpected commit date %q: %w", committed, err)
+	}
+	annotations := map[string]string{
+		ocispec.AnnotationRevision: revision,
+		ocispec.AnnotationCreated:  created.UTC().Format(time.RFC3339),
+	}
+	if remote, err := git(dir, "config", "--get", "remote.origin.url"); err == nil && remote != "" {
+		annotations[ocispec.AnnotationSource] = SourceURL(remote)
+	}
+	return annotations, nil
+}
+
+// SourceURL turns a git remote URL into a URL to browse the source at: scp-like SSH
+// remotes (git@github.com:org/repo.git) become https URLs, and credentials and the
+// .git suffix are removed
+func SourceURL(remote string) string {
+	if !strings.Contains(remote, "://") {
+		if host, path, ok := strings.Cut(remote, ":"); ok && !strings.Contains(host, "/") {
+			if i := strings.LastIndex(host, "@"); i >= 0 {
+				host = host[i+1:]
+			}
+			remote = "https://" + host + "/" + strings.TrimPrefix(path, "/")
+		}
+	}
+	u, err := url.Parse(remote)
+	if err != nil {
+		return remote
+	}
+	if u.Scheme == "ssh" || u.Scheme == "git" {
+		u.Scheme = "https"
+		u.Host = u.Hostname()
+	}
+	u.User = nil
+	u.Path = strings.TrimSuffix(u.Path, ".git")
+	return u.String()
+}
+
+func git(dir string, args ...string) (string, error) {
+	out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+			return "", fmt.Errorf("git %s: %s", strings.Join(args, " "), strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", fmt.Errorf("git %s: %w", strings.Join(args, " "), err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
@@ -1,42 +1,131 @@
 package util
 
-import "fmt"
+import (
+	_ "embed"
+	"fmt"
+	"os"
+	"path/filepath"
 
-//go:generate go run osgen.go
+	"github.com/containerd/platforms"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	yaml "gopkg.in/yaml.v3"
+)
+
+//go:embed platforms.yaml
+var defaultPlatforms []byte
+
+// PlatformConfig is the format of a platform config file: operating systems, and
+// architectures with the variants they can have
+type PlatformConfig struct {
+	OS            []string            `yaml:"os"`
+	Architectures map[string][]string `yaml:"architectures"`
+}
 
 var (
-	armVariants = map[string]bool{
-		"v5": true,
-		"v6": true,
-		"v7": true,
-	}
+	validOS = map[string]bool{}
+	// validArch maps each valid architecture to its valid variants
+	validArch = map[string]map[string]bool{}
+
+	skipPlatformValidation bool
 )
 
-// IsValidOSArch checks against the generated list of os/arch combinations
-// from Go as well as checking for valid variants for ARM (the only architecture that uses variants)
+func init() {
+	if err := addPlatforms(defaultPlatforms); err != nil {
+		panic(fmt.Sprintf("invalid built-in platforms: %v", err))
+	}
+}
+
+// PlatformConfigFile returns the default path of the platform config file, under
+// the user's config directory (e.g. $XDG_CONFIG_HOME on Linux)
+func PlatformConfigFile() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "manifest-tool", "platforms.yaml")
+}
+
+// LoadPlatformConfig adds the operating systems, architectures and variants of a
+// platform config file to the platforms IsValidOSArch accepts
+func LoadPlatformConfig(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := addPlatforms(data); err != nil {
+		return fmt.Errorf("invalid platform config %s: %w", path, err)
+	}
+	return nil
+}
+
+// SkipPlatformValidation makes IsValidOSArch accept any os, architecture and variant
+func SkipPlatformValidation(skip bool) {
+	skipPlatformValidation = skip
+}
+
+func addPlatforms(data []byte) error {
+	var config PlatformConfig
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return err
+	}
+	for _, os := range config.OS {
+		if os == "" {
+			return fmt.Errorf("empty os")
+		}
+		validOS[os] = true
+	}
+	for arch, variants := range config.Architectures {
+		if arch == "" {
+			return fmt.Errorf("empty architecture")
+		}
+		if validArch[arch] == nil {
+			validArch[arch] = map[string]bool{}
+		}
+		for _, variant := range variants {
+			if variant == "" {
+				return fmt.Errorf("empty variant of architecture %s", arch)
+			}
+			validArch[arch][variant] = true
+		}
+	}
+	return nil
+}
+
+// IsValidOSArch checks an os/arch/variant combination against the known platforms:
+// built-in ones and those added by LoadPlatformConfig. A variant is valid for its
+// architecture on any os.
 func IsValidOSArch(os string, arch string, variant string) bool {
-	osarch := fmt.Sprintf("%s/%s", os, arch)
-	if _, ok := validOS[os]; !ok {
+	if os == "" || arch == "" {
 		return false
 	}
-	if _, ok := validArch[arch]; !ok {
+	if skipPlatformValidation {
+		return true
+	}
+	if !validOS[os] {
 		return false
 	}
-	if variant == "" {
-		return true
+	variants, ok := validArch[arch]
+	if !ok {
+		return false
 	}
+	return variant == "" || variants[variant]
+}
 
-	// only arm/arm64 can use variant
-	switch osarch {
-	case "linux/arm":
-		_, ok := armVariants[variant]
-		return ok
-	case "linux/arm64":
-		if variant == "v8" {
-			return true
-		}
-	default:
-		return false
+// NormalizePlatform replaces os and architecture aliases (such as aarch64, x86_64 or armhf)
+// and variant spellings (such as 7 for v7) with their OCI names, following the containerd
+// platform rules. A variant which containerd considers the default of its architecture,
+// such as v8 of arm64, is kept as declared rather than removed.
+func NormalizePlatform(platform ocispec.Platform) ocispec.Platform {
+	normalized := platforms.Normalize(platform)
+	if platform.OS != "" {
+		// containerd defaults an empty os to the one of the host
+		platform.OS = normalized.OS
+	}
+	if normalized.Architecture != platform.Architecture {
+		platform.Architecture = normalized.Architecture
+		platform.Variant = normalized.Variant
+	} else if platform.Variant != "" && normalized.Variant != "" {
+		platform.Variant = normalized.Variant
 	}
-	return false
+	return platform
 }
@@ -1,6 +1,12 @@
 package util
 
-import "testing"
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
 
 func TestValidOSArch(t *testing.T) {
 	var crctosarch = []struct {
@@ -20,6 +26,13 @@ func TestValidOSArch(t *testing.T) {
 		{arch: "linux", os: "arm", variant: "v7"},
 		{arch: "linux", os: "arm64"},
 		{arch: "linux", os: "arm64", variant: "v8"},
+		{arch: "linux", os: "arm64", variant: "v9"},
+		{arch: "linux", os: "amd64", variant: "v2"},
+		{arch: "linux", os: "amd64", variant: "v3"},
+		{arch: "linux", os: "amd64", variant: "v4"},
+		{arch: "linux", os: "riscv64", variant: "rva20u64"},
+		{arch: "freebsd", os: "arm", variant: "v7"},
+		{arch: "windows", os: "arm64", variant: "v8"},
 		{arch: "linux", os: "mips64"},
 		{arch: "linux", os: "mips64le"},
 		{arch: "linux", os: "ppc64"},
@@ -44,6 +57,9 @@ func TestValidOSArch(t *testing.T) {
 		{arch: "abc", os: "123"},
 		{arch: "xyz", os: "etc"},
 		{arch: "", os: ""},
+		{arch: "linux", os: "amd64", variant: "v8"},
+		{arch: "linux", os: "arm", variant: "v4"},
+		{arch: "linux", os: "s390x", variant: "v1"},
 	}
 
 	for _, i := range crctosarch {
@@ -59,5 +75,63 @@ func TestValidOSArch(t *testing.T) {
 			t.Errorf("%s/%s/%s is an invalid os/arch or os/arch/variant combination", j.arch, j.os, j.variant)
 		}
 	}
+}
+
+func TestPlatformConfig(t *testing.T) {
+	defer SkipPlatformValidation(false)
+	config := filepath.Join(t.TempDir(), "platforms.yaml")
+	if err := os.WriteFile(config, []byte("os: [zos]\narchitectures:\n  s390x: [z15]\n  sparc64: []\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if IsValidOSArch("zos", "s390x", "z15") || IsValidOSArch("linux", "sparc64", "") {
+		t.Fatal("platforms of the config are valid before loading it")
+	}
+	if err := LoadPlatformConfig(config); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range [][3]string{{"zos", "s390x", "z15"}, {"linux", "s390x", "z15"}, {"linux", "sparc64", ""}, {"zos", "amd64", "v2"}} {
+		if !IsValidOSArch(p[0], p[1], p[2]) {
+			t.Errorf("%s/%s/%s is valid with the platform config", p[0], p[1], p[2])
+		}
+	}
+	if IsValidOSArch("linux", "sparc64", "v9") {
+		t.Error("linux/sparc64/v9 is not valid with the platform config")
+	}
+
+	if err := os.WriteFile(config, []byte("architectures:\n  arm: [\"\"]\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadPlatformConfig(config); err == nil {
+		t.Error("expected an error for an empty variant")
+	}
+
+	SkipPlatformValidation(true)
+	if !IsValidOSArch("abc", "xyz", "v1") {
+		t.Error("abc/xyz/v1 is valid when skipping platform validation")
+	}
+	if IsValidOSArch("", "amd64", "") {
+		t.Error("a platform without an os is not valid when skipping platform validation")
+	}
+}
 
+func TestNormalizePlatform(t *testing.T) {
+	var tests = []struct {
+		in, want ocispec.Platform
+	}{
+		{in: ocispec.Platform{OS: "linux", Architecture: "aarch64"}, want: ocispec.Platform{OS: "linux", Architecture: "arm64"}},
+		{in: ocispec.Platform{OS: "linux", Architecture: "x86_64"}, want: ocispec.Platform{OS: "linux", Architecture: "amd64"}},
+		{in: ocispec.Platform{OS: "linux", Architecture: "armhf"}, want: ocispec.Platform{OS: "linux", Architecture: "arm", Variant: "v7"}},
+		{in: ocispec.Platform{OS: "Linux", Architecture: "i386"}, want: ocispec.Platform{OS: "linux", Architecture: "386"}},
+		{in: ocispec.Platform{OS: "linux", Architecture: "arm", Variant: "6"}, want: ocispec.Platform{OS: "linux", Architecture: "arm", Variant: "v6"}},
+		{in: ocispec.Platform{OS: "linux", Architecture: "arm64", Variant: "v8"}, want: ocispec.Platform{OS: "linux", Architecture: "arm64", Variant: "v8"}},
+		{in: ocispec.Platform{OS: "linux", Architecture: "arm"}, want: ocispec.Platform{OS: "linux", Architecture: "arm"}},
+		{in: ocispec.Platform{OS: "windows", Architecture: "amd64", OSVersion: "10.0.17763.2565"}, want: ocispec.Platform{OS: "windows", Architecture: "amd64", OSVersion: "10.0.17763.2565"}},
+		{in: ocispec.Platform{Architecture: "amd64"}, want: ocispec.Platform{Architecture: "amd64"}},
+	}
+	for _, tt := range tests {
+		got := NormalizePlatform(tt.in)
+		if got.OS != tt.want.OS || got.Architecture != tt.want.Architecture || got.Variant != tt.want.Variant || got.OSVersion != tt.want.OSVersion {
+			t.Errorf("NormalizePlatform(%+v) = %+v, expected %+v", tt.in, got, tt.want)
+		}
+	}
 }
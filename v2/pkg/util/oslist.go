@@ -1,39 +0,0 @@
-// Code generated by go generate; DO NOT EDIT.
-// This file was generated by robots at
-// 2024-11-21 13:51:14.615514969 +0800 CST m=+0.010073007
-// using data from 'go tool dist list'
-package util
-
-var validOS = map[string]bool{
-	"aix": true,
-	"android": true,
-	"darwin": true,
-	"dragonfly": true,
-	"freebsd": true,
-	"illumos": true,
-	"ios": true,
-	"js": true,
-	"linux": true,
-	"netbsd": true,
-	"openbsd": true,
-	"plan9": true,
-	"solaris": true,
-	"wasip1": true,
-	"windows": true,
-}
-var validArch = map[string]bool{
-	"386": true,
-	"amd64": true,
-	"arm": true,
-	"arm64": true,
-	"loong64": true,
-	"mips": true,
-	"mips64": true,
-	"mips64le": true,
-	"mipsle": true,
-	"ppc64": true,
-	"ppc64le": true,
-	"riscv64": true,
-	"s390x": true,
-	"wasm": true,
-}
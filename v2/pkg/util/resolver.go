@@ -7,6 +7,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 
 	"github.com/containerd/containerd/v2/core/remotes"
 	"github.com/containerd/containerd/v2/core/remotes/docker"
@@ -15,6 +16,8 @@ import (
 	"github.com/docker/cli/cli/config/credentials"
 	"github.com/docker/distribution/reference"
 	"github.com/docker/docker/pkg/homedir"
+	"github.com/estesp/manifest-tool/v2/pkg/types"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/sirupsen/logrus"
 )
 
@@ -22,11 +25,28 @@ var (
 	configDir     = os.Getenv("DOCKER_CONFIG")
 	configFileDir = ".docker"
 	registryHost  docker.RegistryHost
+	// registryHosts holds the host configuration created for each registry, so that
+	// references to multiple registries can be resolved concurrently and references
+	// to the same registry share one authorizer (and its cached tokens)
+	registryHosts = map[string]docker.RegistryHost{}
+	hostsLock     sync.RWMutex
 )
 
 func CreateRegistryHost(imageRef reference.Named, username, password string, insecure, plainHTTP bool, dockerConfigPath string, pushOp bool) error {
 
-	hostname, _ := splitHostname(imageRef.String())
+	refHostname, _ := splitHostname(imageRef.String())
+
+	hostsLock.Lock()
+	defer hostsLock.Unlock()
+	if existing, ok := registryHosts[refHostname]; ok {
+		// reuse the existing configuration unless push capability is now required
+		if !pushOp || existing.Capabilities.Has(docker.HostCapabilityPush) {
+			registryHost = existing
+			return nil
+		}
+	}
+
+	hostname := refHostname
 	if hostname == "docker.io" {
 		hostname = "registry-1.docker.io"
 	}
@@ -98,6 +118,7 @@ func CreateRegistryHost(imageRef reference.Named, username, password string, ins
 
 	}
 	registryHost.Authorizer = docker.NewDockerAuthorizer(docker.WithAuthCreds(credFunc))
+	registryHosts[refHostname] = registryHost
 
 	return nil
 }
@@ -106,11 +127,28 @@ func GetResolver() remotes.Resolver {
 
 	opts := docker.ResolverOptions{
 		Hosts: getHosts,
+		Headers: http.Header{
+			// accept legacy schema 1 manifests as well as containerd's default list of
+			// types so that old images can be inspected and converted
+			"Accept": []string{strings.Join([]string{
+				types.MediaTypeDockerSchema2Manifest,
+				types.MediaTypeDockerSchema2ManifestList,
+				ocispec.MediaTypeImageManifest,
+				ocispec.MediaTypeImageIndex,
+				types.MediaTypeDockerSchema1Manifest,
+				"*/*",
+			}, ", ")},
+		},
 	}
 	return docker.NewResolver(opts)
 }
 
 func getHosts(name string) ([]docker.RegistryHost, error) {
+	hostsLock.RLock()
+	defer hostsLock.RUnlock()
+	if host, ok := registryHosts[name]; ok {
+		return []docker.RegistryHost{host}, nil
+	}
 	return []docker.RegistryHost{registryHost}, nil
 }
 
@@ -132,3 +170,13 @@ func init() {
 func ConfigDir() string {
 	return configDir
 }
+
+// CacheDir returns the default directory for the persistent content cache,
+// under the user's cache directory (e.g. $XDG_CACHE_HOME on Linux)
+func CacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "manifest-tool")
+}
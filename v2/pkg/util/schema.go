@@ -0,0 +1,273 @@
+package util
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/estesp/manifest-tool/v2/pkg/types"
+	yaml "gopkg.in/yaml.v3"
+)
+
+// SpecSchema is the JSON Schema of a YAML spec for "push from-spec"
+//
+//go:embed spec.schema.json
+var SpecSchema []byte
+
+// specSchema is the schema node tree built from SpecSchema which YAML specs are validated against
+var specSchema = mustParseSchema(SpecSchema)
+
+// jsonSchema contains the JSON Schema keywords used by SpecSchema
+type jsonSchema struct {
+	Ref                  string                 `json:"$ref"`
+	Title                string                 `json:"title"`
+	Type                 json.RawMessage        `json:"type"`
+	Properties           map[string]*jsonSchema `json:"properties"`
+	Required             []string               `json:"required"`
+	AdditionalProperties json.RawMessage        `json:"additionalProperties"`
+	Items                *jsonSchema            `json:"items"`
+	OneOf                []*jsonSchema          `json:"oneOf"`
+	Defs                 map[string]*jsonSchema `json:"$defs"`
+}
+
+// schemaNode is the part of a JSON Schema which a YAML spec node is validated against:
+// its types, the properties of a mapping and the items of a sequence, or alternative
+// schemas chosen by the type of the node. The "pattern" keyword is not checked, as the
+// values it applies to (platforms) are validated when they are decoded.
+type schemaNode struct {
+	name       string
+	types      []string
+	properties map[string]*schemaNode
+	required   []string
+	// additional is the schema of values of keys which are not properties; closed
+	// is set if such keys are not allowed
+	additional *schemaNode
+	closed     bool
+	items      *schemaNode
+	oneOf      []*schemaNode
+}
+
+func mustParseSchema(b []byte) *schemaNode {
+	node, err := parseSchema(b)
+	if err != nil {
+		panic(fmt.Sprintf("invalid spec JSON Schema: %v", err))
+	}
+	return node
+}
+
+// parseSchema builds the schema node tree of a JSON Schema, where references are
+// limited to the definitions of the schema ("#/$defs/<name>")
+func parseSchema(b []byte) (*schemaNode, error) {
+	var root jsonSchema
+	if err := json.Unmarshal(b, &root); err != nil {
+		return nil, err
+	}
+	defs := make(map[string]*schemaNode, len(root.Defs))
+	for name := range root.Defs {
+		defs[name] = &schemaNode{}
+	}
+	for name, def := range root.Defs {
+		node, err := buildSchema(def, defs)
+		if err != nil {
+			return nil, fmt.Errorf("definition %s: %w", name, err)
+		}
+		*defs[name] = *node
+	}
+	return buildSchema(&root, defs)
+}
+
+func buildSchema(s *jsonSchema, defs map[string]*schemaNode) (*schemaNode, error) {
+	if s.Ref != "" {
+		def, ok := defs[strings.TrimPrefix(s.Ref, "#/$defs/")]
+		if !ok {
+			return nil, fmt.Errorf("unsupported reference %q", s.Ref)
+		}
+		return def, nil
+	}
+	node := &schemaNode{name: s.Title, required: s.Required}
+	if len(s.Type) > 0 {
+		if err := json.Unmarshal(s.Type, &node.types); err != nil {
+			var t string
+			if err := json.Unmarshal(s.Type, &t); err != nil {
+				return nil, fmt.Errorf("invalid type %s", s.Type)
+			}
+			node.types = []string{t}
+		}
+	}
+	if len(s.Properties) > 0 {
+		node.properties = make(map[string]*schemaNode, len(s.Properties))
+		for key, property := range s.Properties {
+			child, err := buildSchema(property, defs)
+			if err != nil {
+				return nil, fmt.Errorf("property %s: %w", key, err)
+			}
+			node.properties[key] = child
+		}
+	}
+	if len(s.AdditionalProperties) > 0 {
+		var allowed bool
+		if err := json.Unmarshal(s.AdditionalProperties, &allowed); err == nil {
+			node.closed = !allowed
+		} else {
+			var additional jsonSchema
+			if err := json.Unmarshal(s.AdditionalProperties, &additional); err != nil {
+				return nil, fmt.Errorf("invalid additionalProperties: %w", err)
+			}
+			child, err := buildSchema(&additional, defs)
+			if err != nil {
+				return nil, err
+			}
+			node.additional = child
+		}
+	}
+	if s.Items != nil {
+		items, err := buildSchema(s.Items, defs)
+		if err != nil {
+			return nil, err
+		}
+		node.items = items
+	}
+	for _, alt := range s.OneOf {
+		child, err := buildSchema(alt, defs)
+		if err != nil {
+			return nil, err
+		}
+		node.oneOf = append(node.oneOf, child)
+	}
+	return node, nil
+}
+
+// validate returns an error at the position of the first node of the tree which does
+// not match the schema: a value of the wrong type, a missing required key or a key
+// which is not allowed, such as a misspelled key which would otherwise be ignored.
+// The name is the name of a mapping used in errors when the schema has no title, and
+// the label describes the node in errors about its value.
+func (s *schemaNode) validate(node *yaml.Node, name, label string) error {
+	if node.Kind == yaml.AliasNode && node.Alias != nil {
+		node = node.Alias
+	}
+	if s.name != "" {
+		name = s.name
+	}
+	kind := nodeType(node)
+	if len(s.oneOf) > 0 {
+		var expected []string
+		for _, alt := range s.oneOf {
+			if alt.allows(kind) {
+				return alt.validate(node, name, label)
+			}
+			expected = append(expected, alt.types...)
+		}
+		return specError(node, "%s must be %s, not %s", label, typeNames(expected), typeName(kind))
+	}
+	if !s.allows(kind) {
+		return specError(node, "%s must be %s, not %s", label, typeNames(s.types), typeName(kind))
+	}
+	switch node.Kind {
+	case yaml.MappingNode:
+		present := make(map[string]bool, len(node.Content)/2)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i]
+			present[key.Value] = true
+			child, ok := s.properties[key.Value]
+			if !ok {
+				if s.closed {
+					return specError(key, "unknown key %q in %s (expected one of: %s)", key.Value, name, strings.Join(s.keys(), ", "))
+				}
+				child = s.additional
+			}
+			if child == nil {
+				continue
+			}
+			if err := child.validate(node.Content[i+1], key.Value, fmt.Sprintf("key %q in %s", key.Value, name)); err != nil {
+				return err
+			}
+		}
+		for _, key := range s.required {
+			if !present[key] {
+				return specError(node, "missing required key %q in %s", key, name)
+			}
+		}
+	case yaml.SequenceNode:
+		if s.items == nil {
+			return nil
+		}
+		for i, item := range node.Content {
+			if err := s.items.validate(item, name, fmt.Sprintf("entry %d of %s", i+1, label)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// allows returns whether a value of the JSON type is allowed by the types of the schema
+func (s *schemaNode) allows(kind string) bool {
+	if len(s.types) == 0 {
+		return true
+	}
+	for _, t := range s.types {
+		if t == kind || (t == "number" && kind == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *schemaNode) keys() []string {
+	keys := make([]string, 0, len(s.properties))
+	for k := range s.properties {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// nodeType returns the JSON type of the value of a YAML node
+func nodeType(node *yaml.Node) string {
+	switch node.Kind {
+	case yaml.MappingNode:
+		return "object"
+	case yaml.SequenceNode:
+		return "array"
+	}
+	switch node.ShortTag() {
+	case "!!int":
+		return "integer"
+	case "!!float":
+		return "number"
+	case "!!bool":
+		return "boolean"
+	case "!!null":
+		return "null"
+	}
+	return "string"
+}
+
+func typeName(kind string) string {
+	switch kind {
+	case "object":
+		return "a mapping"
+	case "array":
+		return "a list"
+	case "integer":
+		return "an integer"
+	case "null":
+		return "empty"
+	}
+	return "a " + kind
+}
+
+func typeNames(kinds []string) string {
+	names := make([]string, len(kinds))
+	for i, kind := range kinds {
+		names[i] = typeName(kind)
+	}
+	return strings.Join(names, " or ")
+}
+
+func specError(node *yaml.Node, format string, args ...interface{}) error {
+	return &types.SpecError{Line: node.Line, Column: node.Column, Err: fmt.Errorf(format, args...)}
+}
@@ -0,0 +1,146 @@
+package util
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/estesp/manifest-tool/v2/pkg/types"
+)
+
+func TestUnknownKeys(t *testing.T) {
+	var tests = []struct {
+		spec         string
+		line, column int
+		err          string
+	}{
+		{
+			spec:   "image: a\nannotation:\n  a: b\n",
+			line:   2,
+			column: 1,
+			err:    `unknown key "annotation" in manifest list definition`,
+		},
+		{
+			spec:   "image: a\nmanifests:\n  - image: b\n    platfrom:\n      os: linux\n",
+			line:   4,
+			column: 5,
+			err:    `unknown key "platfrom" in manifest entry (expected one of: annotations, convert, image, nested, oci, platform, platforms)`,
+		},
+		{
+			spec:   "- image: a\n  manifests:\n    - image: b\n      platform:\n        os.version: 10.0\n",
+			line:   5,
+			column: 9,
+			err:    `unknown key "os.version" in platform`,
+		},
+		{
+			spec:   "image: a\ntemplate: b-ARCH\nplatforms:\n  - platform: linux/amd64\n    tag: x\n",
+			line:   5,
+			column: 5,
+			err:    `unknown key "tag" in platform entry`,
+		},
+	}
+	for _, tt := range tests {
+		_, err := ParseYAMLInputs([]byte(tt.spec), nil)
+		var specErr *types.SpecError
+		if !errors.As(err, &specErr) {
+			t.Errorf("expected a spec error, got %v", err)
+			continue
+		}
+		if specErr.Line != tt.line || specErr.Column != tt.column || !strings.Contains(specErr.Err.Error(), tt.err) {
+			t.Errorf("expected error %q at %d:%d, got %v", tt.err, tt.line, tt.column, err)
+		}
+	}
+
+	// any annotation keys are allowed
+	if _, err := ParseYAMLInputs([]byte("image: a\nannotations:\n  org.example/any: b\n"), nil); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+// TestSpecSchema checks the validation of specs against the JSON Schema for required
+// keys and the types of values
+func TestSpecSchema(t *testing.T) {
+	var tests = []struct {
+		spec         string
+		line, column int
+		err          string
+	}{
+		{
+			spec:   "tags: [a]\nmanifests:\n  - image: b\n",
+			line:   1,
+			column: 1,
+			err:    `missing required key "image" in manifest list definition`,
+		},
+		{
+			spec:   "image: a\nmanifests:\n  - platform:\n      os: linux\n",
+			line:   3,
+			column: 5,
+			err:    `missing required key "image" in manifest entry`,
+		},
+		{
+			spec:   "image: a\nmanifests:\n  - image: b\n    platform:\n      os: 10\n",
+			line:   5,
+			column: 11,
+			err:    `key "os" in platform must be a string, not an integer`,
+		},
+		{
+			spec:   "image: a\nmanifests:\n  image: b\n",
+			line:   3,
+			column: 3,
+			err:    `key "manifests" in manifest list definition must be a list, not a mapping`,
+		},
+		{
+			spec:   "image: a\ntags:\n  - x\n  - [y]\n",
+			line:   4,
+			column: 5,
+			err:    `entry 2 of key "tags" in manifest list definition must be a string, not a list`,
+		},
+		{
+			spec:   "image: a\nmanifests:\n  - image: b\n    nested: yes please\n",
+			line:   4,
+			column: 13,
+			err:    `key "nested" in manifest entry must be a boolean, not a string`,
+		},
+		{
+			spec:   "image: a\nannotations:\n  a:\n    b: c\n",
+			line:   4,
+			column: 5,
+			err:    `key "a" in annotations must be a string, not a mapping`,
+		},
+		{
+			spec:   "image: a\ntemplate: b-ARCH\nplatforms:\n  - [linux/amd64]\n",
+			line:   4,
+			column: 5,
+			err:    `entry 1 of key "platforms" in manifest list definition must be a string or a mapping, not a list`,
+		},
+		{
+			spec:   "image: a\ntemplate: b-ARCH\nplatforms:\n  - image: b\n",
+			line:   4,
+			column: 5,
+			err:    `missing required key "platform" in platform entry`,
+		},
+		{
+			spec:   "- image: a\n- b\n",
+			line:   2,
+			column: 3,
+			err:    `entry 2 of spec must be a mapping, not a string`,
+		},
+	}
+	for _, tt := range tests {
+		_, err := ParseYAMLInputs([]byte(tt.spec), nil)
+		var specErr *types.SpecError
+		if !errors.As(err, &specErr) {
+			t.Errorf("expected a spec error, got %v", err)
+			continue
+		}
+		if specErr.Line != tt.line || specErr.Column != tt.column || specErr.Err.Error() != tt.err {
+			t.Errorf("expected error %q at %d:%d, got %v", tt.err, tt.line, tt.column, err)
+		}
+	}
+
+	// an integer architecture and variables in strings are allowed
+	spec := "image: a\nmanifests:\n  - image: ${IMAGE}\n    platform:\n      os: linux\n      architecture: 386\n"
+	if _, err := ParseYAMLInputs([]byte(spec), map[string]string{"IMAGE": "b"}); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+}
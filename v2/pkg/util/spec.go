@@ -0,0 +1,221 @@
+package util
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/containerd/platforms"
+	"github.com/estesp/manifest-tool/v2/pkg/types"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	yaml "gopkg.in/yaml.v3"
+)
+
+// ParseYAMLInputs decodes the YAML spec for one or more manifest list/index pushes:
+// each YAML document of the spec is either a single definition or a list of them.
+// Each document is validated against SpecSchema: keys which are not part of the spec,
+// missing required keys and values of the wrong type are an error.
+// References to ${VAR} and ${VAR:-default} in its values are expanded, with variables
+// looked up in vars first and then in the environment; "$$" is a literal "$". A
+// reference to an undefined variable (without a default) is an error as well. Errors
+// at a position of the spec are returned as a *types.SpecError.
+func ParseYAMLInputs(content []byte, vars map[string]string) ([]types.YAMLInput, error) {
+	lookup := func(name string) (string, bool) {
+		if value, ok := vars[name]; ok {
+			return value, true
+		}
+		return os.LookupEnv(name)
+	}
+	var inputs []types.YAMLInput
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	for {
+		var doc yaml.Node
+		if err := decoder.Decode(&doc); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, err
+		}
+		root := &doc
+		if root.Kind == yaml.DocumentNode && len(root.Content) > 0 {
+			root = root.Content[0]
+		}
+		if err := expandNode(&doc, lookup); err != nil {
+			return nil, err
+		}
+		if root.Kind == yaml.MappingNode || root.Kind == yaml.SequenceNode {
+			if err := specSchema.validate(root, "spec", "spec"); err != nil {
+				return nil, err
+			}
+		}
+		switch root.Kind {
+		case yaml.SequenceNode:
+			var list []types.YAMLInput
+			if err := root.Decode(&list); err != nil {
+				return nil, err
+			}
+			for i := range list {
+				if err := expandPlatforms(&list[i]); err != nil {
+					return nil, err
+				}
+			}
+			inputs = append(inputs, list...)
+		case yaml.MappingNode:
+			var input types.YAMLInput
+			if err := root.Decode(&input); err != nil {
+				return nil, err
+			}
+			if err := expandPlatforms(&input); err != nil {
+				return nil, err
+			}
+			inputs = append(inputs, input)
+		default:
+			// an empty document, such as after a trailing "---"
+			if root.Kind != yaml.DocumentNode && !(root.Kind == yaml.ScalarNode && root.Tag == "!!null") {
+				return nil, &types.SpecError{Line: root.Line, Column: root.Column, Err: fmt.Errorf("expected a manifest list definition or a list of them")}
+			}
+		}
+	}
+	if len(inputs) == 0 {
+		return nil, fmt.Errorf("no manifest list definitions found")
+	}
+	return inputs, nil
+}
+
+// expandPlatforms adds a manifest entry to the input for each of its template platforms
+// which is not excluded, with the image named by the template (as for "push from-args")
+// unless the platform entry overrides it. An excluded platform without a variant
+// excludes all variants of its os/arch.
+func expandPlatforms(input *types.YAMLInput) error {
+	var excluded []ocispec.Platform
+	for _, exclude := range input.Exclude {
+		if exclude == "" {
+			// e.g. an optional exclusion from an empty variable
+			continue
+		}
+		platform, err := types.ParsePlatform(exclude)
+		if err != nil {
+			return fmt.Errorf("invalid excluded platform for image %s: %w", input.Image, err)
+		}
+		excluded = append(excluded, platform)
+	}
+	for _, entry := range input.Platforms {
+		if isExcluded(entry.Platform, excluded) {
+			continue
+		}
+		image := entry.Image
+		if image == "" {
+			if input.Template == "" {
+				return fmt.Errorf("platform %s of image %s requires a template or an image", platforms.Format(entry.Platform), input.Image)
+			}
+			var err error
+			image, err = ImageFromTemplate(input.Template, entry.Platform)
+			if err != nil {
+				return fmt.Errorf("platform %s of image %s: %w", platforms.Format(entry.Platform), input.Image, err)
+			}
+		}
+		input.Manifests = append(input.Manifests, types.ManifestEntry{
+			Image:       image,
+			Platform:    entry.Platform,
+			Annotations: entry.Annotations,
+		})
+	}
+	return nil
+}
+
+func isExcluded(platform ocispec.Platform, excluded []ocispec.Platform) bool {
+	for _, e := range excluded {
+		if types.PlatformMatches(e, platform) {
+			return true
+		}
+	}
+	return false
+}
+
+// expandNode expands variable references in the scalar values (not the mapping keys) of a YAML node tree
+func expandNode(node *yaml.Node, lookup func(string) (string, bool)) error {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		value, err := ExpandVars(node.Value, lookup)
+		if err != nil {
+			return &types.SpecError{Line: node.Line, Column: node.Column, Err: err}
+		}
+		node.Value = value
+	case yaml.MappingNode:
+		for i := 1; i < len(node.Content); i += 2 {
+			if err := expandNode(node.Content[i], lookup); err != nil {
+				return err
+			}
+		}
+	default:
+		for _, child := range node.Content {
+			if err := expandNode(child, lookup); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ExpandVars replaces ${VAR} and ${VAR:-default} references in s with the value
+// returned by lookup; the default is used when a variable is undefined or empty.
+// "$$" is replaced with a literal "$" and any other "$" is kept as is.
+func ExpandVars(s string, lookup func(string) (string, bool)) (string, error) {
+	if !strings.Contains(s, "$") {
+		return s, nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '$' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		switch s[i+1] {
+		case '$':
+			b.WriteByte('$')
+			i++
+			continue
+		case '{':
+		default:
+			b.WriteByte(s[i])
+			continue
+		}
+		end := strings.IndexByte(s[i:], '}')
+		if end < 0 {
+			return "", fmt.Errorf("unterminated variable reference in %q", s)
+		}
+		expr := s[i+2 : i+end]
+		name, def, hasDefault := strings.Cut(expr, ":-")
+		if !isVarName(name) {
+			return "", fmt.Errorf("invalid variable reference ${%s}", expr)
+		}
+		value, ok := lookup(name)
+		if hasDefault && value == "" {
+			value, ok = def, true
+		}
+		if !ok {
+			return "", fmt.Errorf("variable %q is not defined (set it in the environment or with --set %s=<value>)", name, name)
+		}
+		b.WriteString(value)
+		i += end
+	}
+	return b.String(), nil
+}
+
+func isVarName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, c := range name {
+		switch {
+		case c == '_', c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z':
+		case c >= '0' && c <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
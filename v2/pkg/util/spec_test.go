@@ -0,0 +1,213 @@
+package util
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/containerd/platforms"
+)
+
+func TestExpandVars(t *testing.T) {
+	lookup := func(name string) (string, bool) {
+		vars := map[string]string{"REGISTRY": "localhost:5000", "EMPTY": ""}
+		value, ok := vars[name]
+		return value, ok
+	}
+	var tests = []struct {
+		in, want, err string
+	}{
+		{in: "plain", want: "plain"},
+		{in: "${REGISTRY}/alpine:latest", want: "localhost:5000/alpine:latest"},
+		{in: "${VERS:-1.0}", want: "1.0"},
+		{in: "${EMPTY:-default}", want: "default"},
+		{in: "${EMPTY}", want: ""},
+		{in: "${REGISTRY:-other}", want: "localhost:5000"},
+		{in: "cost: $$5 or $5", want: "cost: $5 or $5"},
+		{in: "trailing $", want: "trailing $"},
+		{in: "${VERS}", err: `variable "VERS" is not defined`},
+		{in: "${VERS", err: "unterminated variable reference"},
+		{in: "${1X}", err: "invalid variable reference"},
+	}
+	for _, tt := range tests {
+		got, err := ExpandVars(tt.in, lookup)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("ExpandVars(%q): expected error %q, got %v", tt.in, tt.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ExpandVars(%q): unexpected error %v", tt.in, err)
+		} else if got != tt.want {
+			t.Errorf("ExpandVars(%q) = %q, expected %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseYAMLInput(t *testing.T) {
+	spec := `image: ${REGISTRY}/test:${VERS}
+tags: [ "${TAG:-latest}" ]
+manifests:
+  - image: ${REGISTRY}/test:amd64_${VERS}
+    platform:
+      architecture: amd64
+      os: linux
+  - image: ${REGISTRY}/test:riscv64_${UNDEFINED_VAR}
+    platform:
+      architecture: riscv64
+      os: linux
+`
+	t.Setenv("REGISTRY", "localhost:5000")
+	_, err := ParseYAMLInputs([]byte(spec), map[string]string{"VERS": "2.1"})
+	if err == nil || !strings.Contains(err.Error(), `line 8, column 12: variable "UNDEFINED_VAR" is not defined`) {
+		t.Fatalf("expected undefined variable error on line 8, got %v", err)
+	}
+
+	inputs, err := ParseYAMLInputs([]byte(spec), map[string]string{"VERS": "2.1", "UNDEFINED_VAR": "2.1", "REGISTRY": "myreg:5000"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inputs) != 1 {
+		t.Fatalf("expected a single definition, got %d", len(inputs))
+	}
+	input := inputs[0]
+	if input.Image != "myreg:5000/test:2.1" {
+		t.Errorf("unexpected image %q", input.Image)
+	}
+	if len(input.Tags) != 1 || input.Tags[0] != "latest" {
+		t.Errorf("unexpected tags %v", input.Tags)
+	}
+	if len(input.Manifests) != 2 || input.Manifests[0].Image != "myreg:5000/test:amd64_2.1" || input.Manifests[1].Platform.Architecture != "riscv64" {
+		t.Errorf("unexpected manifests %+v", input.Manifests)
+	}
+}
+
+func TestParseMultipleYAMLInputs(t *testing.T) {
+	var tests = []struct {
+		name, spec string
+		images     []string
+		err        string
+	}{
+		{
+			name: "undefined variable",
+			spec: `image: myreg/a:1
+manifests:
+  - image: myreg/a:amd64
+---
+image: myreg/b:1
+manifests:
+  - image: myreg/b:${ARCH}
+---
+`,
+			err: `line 7, column 12: variable "ARCH" is not defined`,
+		},
+		{
+			name: "documents",
+			spec: `image: myreg/a:1
+manifests:
+  - image: myreg/a:amd64
+---
+image: myreg/b:1
+manifests:
+  - image: myreg/b:amd64
+---
+`,
+			images: []string{"myreg/a:1", "myreg/b:1"},
+		},
+		{
+			name: "list",
+			spec: `- image: myreg/a:1
+  manifests:
+    - image: myreg/a:amd64
+- image: myreg/b:1
+  manifests:
+    - image: myreg/b:amd64
+---
+image: myreg/c:1
+`,
+			images: []string{"myreg/a:1", "myreg/b:1", "myreg/c:1"},
+		},
+		{
+			name: "scalar",
+			spec: "just a string\n",
+			err:  "line 1, column 1: expected a manifest list definition",
+		},
+		{
+			name: "empty",
+			spec: "",
+			err:  "no manifest list definitions found",
+		},
+	}
+	for _, tt := range tests {
+		inputs, err := ParseYAMLInputs([]byte(tt.spec), nil)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%s: expected error %q, got %v", tt.name, tt.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+			continue
+		}
+		var images []string
+		for _, input := range inputs {
+			images = append(images, input.Image)
+		}
+		if strings.Join(images, ",") != strings.Join(tt.images, ",") {
+			t.Errorf("%s: expected images %v, got %v", tt.name, tt.images, images)
+		}
+	}
+}
+
+func TestParseTemplatePlatforms(t *testing.T) {
+	spec := `image: myreg/app:${VERS}
+template: myreg/app:OS-ARCHVARIANT-${VERS}
+platforms:
+  - linux/amd64
+  - linux/arm/v6
+  - linux/arm/v7
+  - platform: linux/386
+    image: myreg/app:i386-${VERS}
+  - windows/amd64
+exclude:
+  - linux/arm/v6
+  - ${EXCLUDE:-}
+manifests:
+  - image: myreg/app:extra
+`
+	inputs, err := ParseYAMLInputs([]byte(spec), map[string]string{"VERS": "1.0", "EXCLUDE": "windows/amd64"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, m := range inputs[0].Manifests {
+		entry := m.Image
+		if m.Platform.OS != "" {
+			entry += "=" + platforms.Format(m.Platform)
+		}
+		got = append(got, entry)
+	}
+	want := []string{
+		"myreg/app:extra",
+		"myreg/app:linux-amd64-1.0=linux/amd64",
+		"myreg/app:linux-armv7-1.0=linux/arm/v7",
+		"myreg/app:i386-1.0=linux/386",
+	}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("expected manifests %v, got %v", want, got)
+	}
+
+	var errTests = []struct {
+		spec, err string
+	}{
+		{spec: "image: a\nplatforms:\n  - linux\n", err: `line 3, column 5: invalid platform "linux"`},
+		{spec: "image: a\nplatforms:\n  - linux/amd64\n", err: "platform linux/amd64 of image a requires a template or an image"},
+		{spec: "image: a\ntemplate: b-ARCH\nplatforms:\n  - linux/amd64\nexclude:\n  - amd64\n", err: `invalid excluded platform for image a: invalid platform "amd64"`},
+	}
+	for _, tt := range errTests {
+		if _, err := ParseYAMLInputs([]byte(tt.spec), nil); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("expected error %q, got %v", tt.err, err)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package util
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// imageTemplateData is the data of a Go template image name
+type imageTemplateData struct {
+	OS        string
+	Arch      string
+	Variant   string
+	OSVersion string
+}
+
+// archAliases maps an architecture (or architecture/variant) to the name a distribution
+// uses for it; architectures which are not listed keep their name
+var archAliases = map[string]map[string]string{
+	"uname": {
+		"amd64":    "x86_64",
+		"386":      "i686",
+		"arm64":    "aarch64",
+		"arm":      "armv7l",
+		"arm/v5":   "armv5l",
+		"arm/v6":   "armv6l",
+		"arm/v7":   "armv7l",
+		"mips64le": "mips64",
+	},
+	"debian": {
+		"386":      "i386",
+		"arm":      "armhf",
+		"arm/v5":   "armel",
+		"arm/v6":   "armel",
+		"arm/v7":   "armhf",
+		"ppc64le":  "ppc64el",
+		"mips64le": "mips64el",
+	},
+	"rpm": {
+		"amd64":  "x86_64",
+		"386":    "i686",
+		"arm64":  "aarch64",
+		"arm":    "armv7hl",
+		"arm/v6": "armv6hl",
+		"arm/v7": "armv7hl",
+	},
+}
+
+// archAlias returns the alias function of a table, called with an architecture and
+// optionally its variant, e.g. {{uname .Arch .Variant}}
+func archAlias(table string) func(string, ...string) (string, error) {
+	aliases := archAliases[table]
+	return func(arch string, variant ...string) (string, error) {
+		if len(variant) > 1 {
+			return "", fmt.Errorf("%s: expected an architecture and an optional variant", table)
+		}
+		if len(variant) == 1 && variant[0] != "" {
+			if alias, ok := aliases[arch+"/"+variant[0]]; ok {
+				return alias, nil
+			}
+		}
+		if alias, ok := aliases[arch]; ok {
+			return alias, nil
+		}
+		return arch, nil
+	}
+}
+
+var templateFuncs = template.FuncMap{
+	"uname":  archAlias("uname"),
+	"debian": archAlias("debian"),
+	"rpm":    archAlias("rpm"),
+	"lower":  strings.ToLower,
+	"upper":  strings.ToUpper,
+	// prefix and suffix add a separator to a value unless it is empty, such as
+	// a platform without a variant: {{.Arch}}{{prefix "-" .Variant}}
+	"prefix": func(sep, s string) string {
+		if s == "" {
+			return ""
+		}
+		return sep + s
+	},
+	"suffix": func(sep, s string) string {
+		if s == "" {
+			return ""
+		}
+		return s + sep
+	},
+}
+
+// ImageFromTemplate names the image for a platform from a template. A Go template
+// ("foo/bar:{{.OS}}-{{uname .Arch .Variant}}") is executed with the OS, Arch, Variant
+// and OSVersion of the platform; otherwise the first OS, ARCH and VARIANT in the
+// template are replaced with the values of the platform.
+func ImageFromTemplate(templ string, platform ocispec.Platform) (string, error) {
+	if !strings.Contains(templ, "{{") {
+		return strings.Replace(strings.Replace(strings.Replace(templ, "ARCH", platform.Architecture, 1), "OS", platform.OS, 1), "VARIANT", platform.Variant, 1), nil
+	}
+	t, err := template.New("image").Funcs(templateFuncs).Parse(templ)
+	if err != nil {
+		return "", fmt.Errorf("invalid image template: %w", err)
+	}
+	var b bytes.Buffer
+	data := imageTemplateData{
+		OS:        platform.OS,
+		Arch:      platform.Architecture,
+		Variant:   platform.Variant,
+		OSVersion: platform.OSVersion,
+	}
+	if err := t.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("invalid image template: %w", err)
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,49 @@
+package util
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/estesp/manifest-tool/v2/pkg/types"
+)
+
+func TestImageFromTemplate(t *testing.T) {
+	var tests = []struct {
+		template, platform, want, err string
+	}{
+		{template: "foo/bar-ARCHVARIANT:v1", platform: "linux/arm/v7", want: "foo/bar-armv7:v1"},
+		{template: "foo/bar-OS-ARCH:v1", platform: "linux/amd64", want: "foo/bar-linux-amd64:v1"},
+		{template: "myOS/bar:{{.OS}}-{{.Arch}}{{.Variant}}", platform: "linux/arm/v6", want: "myOS/bar:linux-armv6"},
+		{template: "foo/bar:{{.Arch}}{{prefix \"-\" .Variant}}", platform: "linux/amd64", want: "foo/bar:amd64"},
+		{template: "foo/bar:{{.Arch}}{{prefix \"-\" .Variant}}", platform: "linux/arm64/v8", want: "foo/bar:arm64-v8"},
+		{template: "foo/bar:{{suffix \"-\" .Variant}}{{.Arch}}", platform: "linux/arm/v7", want: "foo/bar:v7-arm"},
+		{template: "foo/bar:{{uname .Arch}}", platform: "linux/amd64", want: "foo/bar:x86_64"},
+		{template: "foo/bar:{{uname .Arch .Variant}}", platform: "linux/arm/v6", want: "foo/bar:armv6l"},
+		{template: "foo/bar:{{uname .Arch .Variant}}", platform: "linux/s390x", want: "foo/bar:s390x"},
+		{template: "foo/bar:{{debian .Arch .Variant}}", platform: "linux/arm/v7", want: "foo/bar:armhf"},
+		{template: "foo/bar:{{debian .Arch .Variant}}", platform: "linux/ppc64le", want: "foo/bar:ppc64el"},
+		{template: "foo/bar:{{rpm .Arch}}", platform: "linux/arm64", want: "foo/bar:aarch64"},
+		{template: "foo/bar:{{.OS | upper}}-{{.Arch | lower}}", platform: "windows/amd64", want: "foo/bar:WINDOWS-amd64"},
+		{template: "foo/bar:{{.Arch}", platform: "linux/amd64", err: "invalid image template"},
+		{template: "foo/bar:{{.Architecture}}", platform: "linux/amd64", err: "can't evaluate field Architecture"},
+		{template: "foo/bar:{{uname .Arch .Variant .OS}}", platform: "linux/amd64", err: "expected an architecture and an optional variant"},
+	}
+	for _, tt := range tests {
+		p, err := types.ParsePlatform(tt.platform)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := ImageFromTemplate(tt.template, p)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("ImageFromTemplate(%q, %s): expected error %q, got %v", tt.template, tt.platform, tt.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ImageFromTemplate(%q, %s): unexpected error %v", tt.template, tt.platform, err)
+		} else if got != tt.want {
+			t.Errorf("ImageFromTemplate(%q, %s) = %q, expected %q", tt.template, tt.platform, got, tt.want)
+		}
+	}
+}